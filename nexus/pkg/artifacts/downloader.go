@@ -64,7 +64,7 @@ func (ad *ArtifactDownloader) getArtifactManifest(artifactID string) (manifest M
 		return Manifest{}, fmt.Errorf("could not access manifest for artifact")
 	}
 	directURL := artifactManifest.GetFile().DirectUrl
-	manifest, err = loadManifestFromURL(directURL)
+	manifest, err = loadManifestFromURLCtx(ad.Ctx, directURL)
 	if err != nil {
 		return Manifest{}, err
 	}
@@ -82,10 +82,12 @@ func (ad *ArtifactDownloader) downloadFiles(artifactID string, manifest Manifest
 
 	// Fetch URLs and download files in batches
 	manifestEntries := manifest.Contents
+	index := newPathIndex(manifestEntries)
 	numInProgress, numDone := 0, 0
 	nameToScheduledTime := map[string]time.Time{}
 	taskResultsChan := make(chan TaskResult)
 	manifestEntriesBatch := make([]ManifestEntry, 0, batchSize)
+	var symlinks []ManifestEntry
 
 	for numDone < len(manifestEntries) {
 		var cursor *string
@@ -108,7 +110,7 @@ func (ad *ArtifactDownloader) downloadFiles(artifactID string, manifest Manifest
 			cursor = response.Artifact.Files.PageInfo.EndCursor
 			for _, edge := range response.GetArtifact().GetFiles().Edges {
 				filePath := edge.GetNode().Name
-				entry, err := manifest.GetManifestEntryFromArtifactFilePath(filePath)
+				entry, err := index.get(filePath)
 				if err != nil {
 					return err
 				}
@@ -120,12 +122,23 @@ func (ad *ArtifactDownloader) downloadFiles(artifactID string, manifest Manifest
 					numDone++
 					continue
 				}
+				// Directory placeholders have no contents to download
+				if entry.IsDir(filePath) {
+					if err := os.MkdirAll(filepath.Join(ad.DownloadRoot, filePath), 0755); err != nil {
+						return err
+					}
+					numDone++
+					continue
+				}
 				node := edge.GetNode()
 				if node == nil {
 					return fmt.Errorf("error reading entry from fetched file urls")
 				}
 				entry.DownloadURL = &node.DirectUrl
 				entry.LocalPath = &filePath
+				if err := entry.ValidateMaterializable(); err != nil {
+					return fmt.Errorf("manifest entry %q: %w", filePath, err)
+				}
 				nameToScheduledTime[*entry.LocalPath] = now
 				manifestEntriesBatch = append(manifestEntriesBatch, entry)
 			}
@@ -135,6 +148,14 @@ func (ad *ArtifactDownloader) downloadFiles(artifactID string, manifest Manifest
 				for _, entry := range manifestEntriesBatch {
 					// Add function that returns download path?
 					downloadLocalPath := filepath.Join(ad.DownloadRoot, *entry.LocalPath)
+					// Symlinks are recreated locally rather than downloaded,
+					// once every regular file is in place, so that no
+					// download is written through a link.
+					if entry.IsSymlink() {
+						symlinks = append(symlinks, entry)
+						numDone++
+						continue
+					}
 					// Skip downloading the file if it already exists
 					if _, err := os.Stat(downloadLocalPath); err == nil {
 						numDone++
@@ -172,6 +193,18 @@ func (ad *ArtifactDownloader) downloadFiles(artifactID string, manifest Manifest
 			}
 		}
 	}
+	for _, entry := range symlinks {
+		if err := entry.CreateSymlink(ad.DownloadRoot, *entry.LocalPath); err != nil {
+			return err
+		}
+	}
+	// Check the links again now that all of them exist, since a later link
+	// can redirect an earlier one.
+	for _, entry := range symlinks {
+		if err := confineSymlink(ad.DownloadRoot, *entry.LocalPath); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
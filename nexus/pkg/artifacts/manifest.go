@@ -1,16 +1,23 @@
 package artifacts
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"net/http"
-	"os"
+	"sort"
+	"strings"
+	"sync"
 
 	"github.com/wandb/wandb/nexus/pkg/service"
 	"github.com/wandb/wandb/nexus/pkg/utils"
 )
 
+// SupportedManifestVersion is the newest manifest version this package
+// understands.
+const SupportedManifestVersion int32 = 1
+
 type Manifest struct {
 	Version             int32                    `json:"version"`
 	StoragePolicy       string                   `json:"storagePolicy"`
@@ -18,6 +25,12 @@ type Manifest struct {
 	Contents            map[string]ManifestEntry `json:"contents"`
 }
 
+// Storage layouts that determine where an artifact's files are stored.
+const (
+	StorageLayoutV1 = "V1"
+	StorageLayoutV2 = "V2"
+)
+
 type StoragePolicyConfig struct {
 	StorageLayout string `json:"storageLayout"`
 }
@@ -28,21 +41,92 @@ type ManifestEntry struct {
 	Ref             *string                `json:"ref,omitempty"`
 	Size            int64                  `json:"size"`
 	Extra           map[string]interface{} `json:"extra,omitempty"`
+	Chunks          []ManifestChunk        `json:"chunks,omitempty"`
+	SymlinkTarget   *string                `json:"symlinkTarget,omitempty"`
+	Tags            []string               `json:"tags,omitempty"`
+	ContentEncoding *string                `json:"contentEncoding,omitempty"`
+	Encryption      *EntryEncryption       `json:"encryption,omitempty"`
 	LocalPath       *string                `json:"-"`
 	DownloadURL     *string                `json:"-"`
 }
 
+// EntryEncryption records how an entry's contents are encrypted at rest, so
+// that they can be decrypted after download.
+type EntryEncryption struct {
+	// Algorithm names the cipher, such as "AES256" or "aws:kms".
+	Algorithm string `json:"algorithm"`
+
+	// KeyID identifies the encryption key, such as a KMS key ARN, if the
+	// algorithm uses a managed key.
+	KeyID string `json:"keyID,omitempty"`
+}
+
+// ManifestChunk is one part of an entry that is stored in multiple parts,
+// such as an S3 multipart upload.
+type ManifestChunk struct {
+	Offset int64  `json:"offset"`
+	Size   int64  `json:"size"`
+	Digest string `json:"digest"`
+}
+
+// Reserved Extra keys under which entry fields that the proto has no field
+// for are carried through it.
+const (
+	protoExtraSymlinkTarget   = "_wandb_symlinkTarget"
+	protoExtraTags            = "_wandb_tags"
+	protoExtraContentEncoding = "_wandb_contentEncoding"
+	protoExtraEncryption      = "_wandb_encryption"
+)
+
 func NewManifestFromProto(proto *service.ArtifactManifest) (Manifest, error) {
+	storageLayout := StorageLayoutV2
+	for _, item := range proto.StoragePolicyConfig {
+		if item.Key != "storageLayout" {
+			continue
+		}
+		err := json.Unmarshal([]byte(item.ValueJson), &storageLayout)
+		if err != nil {
+			return Manifest{}, fmt.Errorf(
+				"manifest storage policy config json.Unmarshal: %w", err,
+			)
+		}
+	}
+	if storageLayout != StorageLayoutV1 && storageLayout != StorageLayoutV2 {
+		return Manifest{}, fmt.Errorf("unsupported storage layout %q", storageLayout)
+	}
+
 	manifest := Manifest{
 		Version:             proto.Version,
 		StoragePolicy:       proto.StoragePolicy,
-		StoragePolicyConfig: StoragePolicyConfig{StorageLayout: "V2"},
+		StoragePolicyConfig: StoragePolicyConfig{StorageLayout: storageLayout},
 		Contents:            make(map[string]ManifestEntry),
 	}
 	for _, entry := range proto.Contents {
 		extra := map[string]interface{}{}
+		var symlinkTarget *string
+		var tags []string
+		var contentEncoding *string
+		var encryption *EntryEncryption
 		for _, item := range entry.Extra {
 			var value interface{}
+			switch item.Key {
+			case protoExtraSymlinkTarget:
+				value = &symlinkTarget
+			case protoExtraTags:
+				value = &tags
+			case protoExtraContentEncoding:
+				value = &contentEncoding
+			case protoExtraEncryption:
+				value = &encryption
+			}
+			if value != nil {
+				if err := json.Unmarshal([]byte(item.ValueJson), value); err != nil {
+					return Manifest{}, fmt.Errorf(
+						"manifest entry %s json.Unmarshal: %w", item.Key, err,
+					)
+				}
+				continue
+			}
 			err := json.Unmarshal([]byte(item.ValueJson), &value)
 			if err != nil {
 				return Manifest{}, fmt.Errorf(
@@ -57,59 +141,308 @@ func NewManifestFromProto(proto *service.ArtifactManifest) (Manifest, error) {
 			Ref:             utils.NilIfZero(entry.Ref),
 			Size:            entry.Size,
 			Extra:           extra,
+			SymlinkTarget:   symlinkTarget,
+			Tags:            tags,
+			ContentEncoding: contentEncoding,
+			Encryption:      encryption,
 			LocalPath:       utils.NilIfZero(entry.LocalPath),
 		}
 	}
+	if err := manifest.Validate(); err != nil {
+		return Manifest{}, err
+	}
 	return manifest, nil
 }
 
-func (m *Manifest) WriteToFile() (filename string, digest string, rerr error) {
-	data, rerr := json.Marshal(m)
-	if rerr != nil {
-		return
+// ToProto converts the manifest into its proto representation. It is the
+// inverse of NewManifestFromProto: nil fields become zero values, and entries
+// are ordered by path.
+func (m *Manifest) ToProto() (*service.ArtifactManifest, error) {
+	storageLayout, err := json.Marshal(m.StoragePolicyConfig.StorageLayout)
+	if err != nil {
+		return nil, fmt.Errorf("manifest storage policy config json.Marshal: %w", err)
+	}
+	proto := &service.ArtifactManifest{
+		Version:       m.Version,
+		StoragePolicy: m.StoragePolicy,
+		StoragePolicyConfig: []*service.StoragePolicyConfigItem{
+			{Key: "storageLayout", ValueJson: string(storageLayout)},
+		},
 	}
 
-	f, rerr := os.CreateTemp("", "tmpfile-")
-	if rerr != nil {
-		return
+	for _, path := range m.SortedPaths() {
+		entry := m.Contents[path]
+		keys := make([]string, 0, len(entry.Extra))
+		for key := range entry.Extra {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		extra := make([]*service.ExtraItem, 0, len(keys))
+		for _, key := range keys {
+			value, err := json.Marshal(entry.Extra[key])
+			if err != nil {
+				return nil, fmt.Errorf(
+					"manifest entry extra json.Marshal: %w", err,
+				)
+			}
+			extra = append(extra, &service.ExtraItem{Key: key, ValueJson: string(value)})
+		}
+		reserved := []struct {
+			key   string
+			value interface{}
+			set   bool
+		}{
+			{protoExtraSymlinkTarget, entry.SymlinkTarget, entry.SymlinkTarget != nil},
+			{protoExtraTags, entry.Tags, len(entry.Tags) > 0},
+			{protoExtraContentEncoding, entry.ContentEncoding, entry.ContentEncoding != nil},
+			{protoExtraEncryption, entry.Encryption, entry.Encryption != nil},
+		}
+		for _, item := range reserved {
+			if !item.set {
+				continue
+			}
+			value, err := json.Marshal(item.value)
+			if err != nil {
+				return nil, fmt.Errorf(
+					"manifest entry %s json.Marshal: %w", item.key, err,
+				)
+			}
+			extra = append(extra, &service.ExtraItem{Key: item.key, ValueJson: string(value)})
+		}
+		proto.Contents = append(proto.Contents, &service.ArtifactManifestEntry{
+			Path:            path,
+			Digest:          entry.Digest,
+			BirthArtifactId: utils.ZeroIfNil(entry.BirthArtifactID),
+			Ref:             utils.ZeroIfNil(entry.Ref),
+			Size:            entry.Size,
+			Extra:           extra,
+			LocalPath:       utils.ZeroIfNil(entry.LocalPath),
+		})
+	}
+	return proto, nil
+}
+
+// Validate checks that the manifest is one this package knows how to handle,
+// and that no entry is both a reference and a stored file.
+func (m *Manifest) Validate() error {
+	if m.Version > SupportedManifestVersion {
+		return fmt.Errorf(
+			"%w %d (max supported version is %d)",
+			ErrManifestVersionUnsupported, m.Version, SupportedManifestVersion,
+		)
+	}
+	var conflicting []string
+	for path, entry := range m.Contents {
+		if entry.isReferenceAndStored() {
+			conflicting = append(conflicting, path)
+		}
+	}
+	if len(conflicting) > 0 {
+		sort.Strings(conflicting)
+		return fmt.Errorf(
+			"manifest entries are both references and stored files: %s",
+			strings.Join(conflicting, ", "),
+		)
+	}
+	return nil
+}
+
+// ValidateSchema checks that the manifest has all required top-level fields,
+// and returns an error listing each one that is missing.
+//
+// A version of 0 is treated as missing, since no manifest has that version.
+func (m *Manifest) ValidateSchema() error {
+	var errs []error
+	if m.Version == 0 {
+		errs = append(errs, errors.New("manifest is missing required field \"version\""))
 	}
-	defer f.Close()
-	_, rerr = f.Write(data)
+	if m.StoragePolicy == "" {
+		errs = append(errs, errors.New("manifest is missing required field \"storagePolicy\""))
+	}
+	if m.Contents == nil {
+		errs = append(errs, errors.New("manifest is missing required field \"contents\""))
+	}
+	return errors.Join(errs...)
+}
+
+// ValidateEntries validates each entry in the manifest and returns one error
+// per invalid entry, sorted by path. Each error names the offending path.
+func (m *Manifest) ValidateEntries() []error {
+	var errs []error
+	paths := m.SortedPaths()
+	for _, path := range paths {
+		entry := m.Contents[path]
+		if err := entry.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("manifest entry %q: %w", path, err))
+		}
+	}
+	return errs
+}
+
+// CheckBirthArtifacts returns, in sorted order, the paths of entries whose
+// BirthArtifactID is set but not in known. Entries with no BirthArtifactID
+// are not checked.
+func (m *Manifest) CheckBirthArtifacts(known map[string]bool) []string {
+	var dangling []string
+	for _, path := range m.SortedPaths() {
+		birthArtifactID := m.Contents[path].BirthArtifactID
+		if birthArtifactID != nil && !known[*birthArtifactID] {
+			dangling = append(dangling, path)
+		}
+	}
+	return dangling
+}
+
+// GroupByBirthArtifact returns the sorted paths of the manifest's entries,
+// grouped by BirthArtifactID. Entries with no BirthArtifactID are grouped
+// under "".
+func (m *Manifest) GroupByBirthArtifact() map[string][]string {
+	groups := make(map[string][]string)
+	for _, path := range m.SortedPaths() {
+		birthArtifactID := utils.ZeroIfNil(m.Contents[path].BirthArtifactID)
+		groups[birthArtifactID] = append(groups[birthArtifactID], path)
+	}
+	return groups
+}
+
+// ValidateEntriesConcurrent runs check on every entry using at most workers
+// goroutines, and returns the errors it reported joined in path order.
+//
+// If ctx is cancelled, no further checks are started and the context's error
+// is included in the result.
+func (m *Manifest) ValidateEntriesConcurrent(
+	ctx context.Context,
+	workers int,
+	check func(ctx context.Context, path string, e ManifestEntry) error,
+) error {
+	if workers < 1 {
+		workers = 1
+	}
+	paths := m.SortedPaths()
+	errs := make([]error, len(paths))
+
+	indices := make(chan int)
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				path := paths[i]
+				if err := check(ctx, path, m.Contents[path]); err != nil {
+					errs[i] = fmt.Errorf("manifest entry %q: %w", path, err)
+				}
+			}
+		}()
+	}
+
+	var ctxErr error
+dispatch:
+	for i := range paths {
+		select {
+		case <-ctx.Done():
+			ctxErr = ctx.Err()
+			break dispatch
+		case indices <- i:
+		}
+	}
+	close(indices)
+	wg.Wait()
+
+	return errors.Join(append(errs, ctxErr)...)
+}
+
+// SortedPaths returns the manifest's paths in lexicographic order.
+func (m *Manifest) SortedPaths() []string {
+	paths := make([]string, 0, len(m.Contents))
+	for path := range m.Contents {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// ForEachSorted calls fn for each entry in the order given by SortedPaths,
+// stopping at and returning the first error fn returns.
+func (m *Manifest) ForEachSorted(fn func(path string, e ManifestEntry) error) error {
+	for _, path := range m.SortedPaths() {
+		if err := fn(path, m.Contents[path]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteToFile writes the manifest to a new file in the default temporary
+// directory. See WriteToFileInDir.
+func (m *Manifest) WriteToFile() (filename string, digest string, rerr error) {
+	return m.WriteToFileInDir("")
+}
+
+// WriteToFileInDir writes the manifest to a new temporary file in dir and
+// returns the file's name along with the manifest's base64-encoded MD5
+// digest. If dir is empty, the default temporary directory is used.
+//
+// On error, the temporary file is removed and no filename is returned.
+func (m *Manifest) WriteToFileInDir(dir string) (filename string, digest string, rerr error) {
+	writer := ManifestWriter{Dir: dir}
+	written, rerr := writer.WriteToFile(m)
 	if rerr != nil {
 		return
 	}
-	filename = f.Name()
+	return written.Filename, written.Digest, nil
+}
+
+// WriteTo writes the manifest as JSON to w. It implements io.WriterTo.
+func (m *Manifest) WriteTo(w io.Writer) (int64, error) {
+	n, _, err := m.WriteToWithDigest(w)
+	return n, err
+}
 
-	digest, rerr = utils.ComputeB64MD5(data)
-	return
+// WriteToWithDigest is like WriteTo, but also returns the base64-encoded MD5
+// digest of the written bytes, as WriteToFile does.
+func (m *Manifest) WriteToWithDigest(w io.Writer) (n int64, digest string, err error) {
+	writer := ManifestWriter{}
+	return writer.Write(w, m)
 }
 
+// GetManifestEntryFromArtifactFilePath returns the entry stored at path.
+//
+// Paths are compared after normalization, so "./dir//file.txt" and
+// `dir\file.txt` both find an entry stored as "dir/file.txt". It is an error
+// if path is not stored verbatim and several stored paths normalize to it.
+//
+// A path that is not stored verbatim costs a pass over the manifest; use
+// GetEntries to look up many paths.
 func (m *Manifest) GetManifestEntryFromArtifactFilePath(path string) (ManifestEntry, error) {
-	manifestEntries := m.Contents
-	manifestEntry, ok := manifestEntries[path]
-	if !ok {
-		return ManifestEntry{}, fmt.Errorf("path not contained in artifact: %s", path)
+	if manifestEntry, ok := m.Contents[path]; ok {
+		return manifestEntry, nil
 	}
-	return manifestEntry, nil
+	return newPathIndex(m.Contents).get(path)
 }
 
-func loadManifestFromURL(url string) (Manifest, error) {
-	resp, err := http.Get(url)
-	if err != nil {
-		return Manifest{}, err
-	}
-	defer resp.Body.Close()
-	manifest := Manifest{}
-	if resp.StatusCode != http.StatusOK {
-		return Manifest{}, fmt.Errorf("request to get manifest from url failed with status code: %d", resp.StatusCode)
-	}
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return Manifest{}, fmt.Errorf("error reading response body: %v", err)
+// Compact removes every Extra key not in keepKeys from all entries, to shrink
+// the serialized manifest. Entries left with no Extra keys get a nil Extra.
+func (m *Manifest) Compact(keepKeys []string) {
+	keep := make(map[string]bool, len(keepKeys))
+	for _, key := range keepKeys {
+		keep[key] = true
 	}
-	err = json.Unmarshal(body, &manifest)
-	if err != nil {
-		return Manifest{}, nil
+	for path, entry := range m.Contents {
+		if len(entry.Extra) == 0 {
+			continue
+		}
+		extra := make(map[string]interface{})
+		for key, value := range entry.Extra {
+			if keep[key] {
+				extra[key] = value
+			}
+		}
+		if len(extra) == 0 {
+			extra = nil
+		}
+		entry.Extra = extra
+		m.Contents[path] = entry
 	}
-	return manifest, nil
 }
@@ -0,0 +1,128 @@
+package artifacts
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ManifestAppender accumulates manifest entries in a temporary file, one JSON
+// object per line, so that a large manifest can be built without holding
+// every entry in memory or re-serializing it as entries are added.
+//
+// Finalize reads the entries back into a Manifest.
+type ManifestAppender struct {
+	file    *os.File
+	writer  *bufio.Writer
+	encoder *json.Encoder
+	digest  string
+}
+
+// appendedEntry is one line of a ManifestAppender's file.
+//
+// LocalPath and DownloadURL are not part of an entry's JSON encoding, but
+// files must still be uploaded from their LocalPath after Finalize, so they
+// are stored alongside it.
+type appendedEntry struct {
+	Path        string        `json:"path"`
+	Entry       ManifestEntry `json:"entry"`
+	LocalPath   *string       `json:"localPath,omitempty"`
+	DownloadURL *string       `json:"downloadURL,omitempty"`
+}
+
+// NewManifestAppender creates an appender backed by a new temporary file in
+// dir. If dir is empty, the default temporary directory is used.
+func NewManifestAppender(dir string) (*ManifestAppender, error) {
+	f, err := os.CreateTemp(dir, "manifest-*.ndjson")
+	if err != nil {
+		return nil, fmt.Errorf("error creating manifest appender file: %w", err)
+	}
+	writer := bufio.NewWriter(f)
+	return &ManifestAppender{
+		file:    f,
+		writer:  writer,
+		encoder: json.NewEncoder(writer),
+	}, nil
+}
+
+// Append validates entry and writes it to the appender's file.
+func (a *ManifestAppender) Append(path string, entry ManifestEntry) error {
+	if a.file == nil {
+		return fmt.Errorf("manifest appender is finalized")
+	}
+	if err := entry.Validate(); err != nil {
+		return fmt.Errorf("manifest entry %q: %w", path, err)
+	}
+	appended := appendedEntry{
+		Path:        path,
+		Entry:       entry,
+		LocalPath:   entry.LocalPath,
+		DownloadURL: entry.DownloadURL,
+	}
+	if err := a.encoder.Encode(appended); err != nil {
+		return fmt.Errorf("error appending manifest entry %q: %w", path, err)
+	}
+	return nil
+}
+
+// Finalize reads the appended entries into a manifest with the same defaults
+// as NewManifestBuilder, computes its digest and removes the appender's file.
+// It is an error for two appended entries to have the same path.
+//
+// The appender cannot be used after Finalize, except to call Digest.
+func (a *ManifestAppender) Finalize() (manifest Manifest, rerr error) {
+	if a.file == nil {
+		return Manifest{}, fmt.Errorf("manifest appender is finalized")
+	}
+	f := a.file
+	a.file = nil
+	defer func() {
+		if err := f.Close(); err != nil && rerr == nil {
+			rerr = err
+		}
+		_ = os.Remove(f.Name())
+	}()
+
+	if err := a.writer.Flush(); err != nil {
+		return Manifest{}, fmt.Errorf("error writing manifest appender file: %w", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return Manifest{}, fmt.Errorf("error reading manifest appender file: %w", err)
+	}
+
+	manifest = NewManifestBuilder().manifest
+	decoder := json.NewDecoder(bufio.NewReader(f))
+	for {
+		var appended appendedEntry
+		err := decoder.Decode(&appended)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return Manifest{}, fmt.Errorf("error reading manifest appender file: %w", err)
+		}
+		if _, ok := manifest.Contents[appended.Path]; ok {
+			return Manifest{}, fmt.Errorf("duplicate manifest entry %q", appended.Path)
+		}
+		appended.Entry.LocalPath = appended.LocalPath
+		appended.Entry.DownloadURL = appended.DownloadURL
+		manifest.Contents[appended.Path] = appended.Entry
+	}
+
+	writer := ManifestWriter{}
+	_, digest, err := writer.Write(io.Discard, &manifest)
+	if err != nil {
+		return Manifest{}, err
+	}
+	a.digest = digest
+	return manifest, nil
+}
+
+// Digest returns the base64-encoded MD5 digest of the finalized manifest, as
+// WriteToFile would compute it, or "" if Finalize has not succeeded.
+func (a *ManifestAppender) Digest() string {
+	return a.digest
+}
@@ -0,0 +1,69 @@
+package artifacts
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManifestAppender(t *testing.T) {
+	const numEntries = 100_000
+	dir := t.TempDir()
+	appender, err := NewManifestAppender(dir)
+	assert.Nil(t, err)
+	builder := NewManifestBuilder()
+	for i := 0; i < numEntries; i++ {
+		path, digest := fmt.Sprintf("dir/file-%d.txt", i), fmt.Sprintf("digest-%d", i)
+		localPath := filepath.Join("/staging", path)
+		entry := ManifestEntry{Digest: digest, Size: int64(i), LocalPath: &localPath}
+		if i%2 == 0 {
+			downloadURL := "https://storage.example.com/" + digest
+			entry.DownloadURL = &downloadURL
+		}
+		assert.Nil(t, appender.Append(path, entry))
+		builder.AddFile(path, digest, int64(i))
+	}
+	expected, err := builder.Build()
+	assert.Nil(t, err)
+	for path, entry := range expected.Contents {
+		localPath := filepath.Join("/staging", path)
+		entry.LocalPath = &localPath
+		if entry.Size%2 == 0 {
+			downloadURL := "https://storage.example.com/" + entry.Digest
+			entry.DownloadURL = &downloadURL
+		}
+		expected.Contents[path] = entry
+	}
+
+	manifest, err := appender.Finalize()
+	assert.Nil(t, err)
+	assert.Equal(t, expected, manifest)
+
+	_, expectedDigest, err := expected.WriteToWithDigest(io.Discard)
+	assert.Nil(t, err)
+	assert.Equal(t, expectedDigest, appender.Digest())
+
+	files, err := os.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Empty(t, files)
+
+	assert.ErrorContains(t, appender.Append("late.txt", ManifestEntry{Digest: "x"}), "finalized")
+	_, err = appender.Finalize()
+	assert.ErrorContains(t, err, "finalized")
+}
+
+func TestManifestAppenderRejectsDuplicates(t *testing.T) {
+	appender, err := NewManifestAppender(t.TempDir())
+	assert.Nil(t, err)
+	assert.Nil(t, appender.Append("a.txt", ManifestEntry{Digest: "x"}))
+	assert.Nil(t, appender.Append("a.txt", ManifestEntry{Digest: "y"}))
+	assert.ErrorContains(t, appender.Append("b.txt", ManifestEntry{}), "missing digest")
+
+	_, err = appender.Finalize()
+	assert.ErrorContains(t, err, `duplicate manifest entry "a.txt"`)
+	assert.Equal(t, "", appender.Digest())
+}
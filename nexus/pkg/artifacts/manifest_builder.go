@@ -0,0 +1,87 @@
+package artifacts
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultStoragePolicy is the storage policy of manifests created by
+// ManifestBuilder.
+const DefaultStoragePolicy = "wandb-storage-policy-v1"
+
+// emptyMD5Digest is the base64-encoded MD5 of no bytes, used as the digest
+// of directory placeholders.
+const emptyMD5Digest = "1B2M2Y8AsgTpgAmY7PhCfg=="
+
+// ManifestBuilder builds a Manifest one entry at a time.
+//
+// Each added entry is validated immediately. The first error stops the
+// builder: later additions are ignored and Build returns that error.
+type ManifestBuilder struct {
+	manifest Manifest
+	err      error
+}
+
+// NewManifestBuilder returns a builder for a manifest with the supported
+// version, the default storage policy and the V2 storage layout.
+func NewManifestBuilder() *ManifestBuilder {
+	return &ManifestBuilder{
+		manifest: Manifest{
+			Version:             SupportedManifestVersion,
+			StoragePolicy:       DefaultStoragePolicy,
+			StoragePolicyConfig: StoragePolicyConfig{StorageLayout: StorageLayoutV2},
+			Contents:            make(map[string]ManifestEntry),
+		},
+	}
+}
+
+// AddFile adds an entry for a file stored with the artifact.
+func (b *ManifestBuilder) AddFile(path, digest string, size int64) *ManifestBuilder {
+	return b.add(path, ManifestEntry{Digest: digest, Size: size})
+}
+
+// AddReference adds an entry for an object stored outside of W&B at ref.
+func (b *ManifestBuilder) AddReference(path, ref string, size int64) *ManifestBuilder {
+	return b.add(path, ManifestEntry{Ref: &ref, Size: size})
+}
+
+// AddDir adds a placeholder entry for the directory at path, so that it is
+// recreated even if it is empty. A trailing "/" is added to path if needed.
+func (b *ManifestBuilder) AddDir(path string) *ManifestBuilder {
+	if !strings.HasSuffix(path, "/") {
+		path += "/"
+	}
+	return b.add(path, ManifestEntry{Digest: emptyMD5Digest})
+}
+
+func (b *ManifestBuilder) add(path string, entry ManifestEntry) *ManifestBuilder {
+	if b.err != nil {
+		return b
+	}
+	if path == "" {
+		b.err = fmt.Errorf("manifest entry has an empty path")
+		return b
+	}
+	if _, ok := b.manifest.Contents[path]; ok {
+		b.err = fmt.Errorf("duplicate manifest entry %q", path)
+		return b
+	}
+	if err := entry.Validate(); err != nil {
+		b.err = fmt.Errorf("manifest entry %q: %w", path, err)
+		return b
+	}
+	b.manifest.Contents[path] = entry
+	return b
+}
+
+// Build returns the manifest, or the first error encountered while adding
+// entries. The builder must not be used afterwards.
+func (b *ManifestBuilder) Build() (Manifest, error) {
+	if b.err != nil {
+		return Manifest{}, b.err
+	}
+	if err := b.manifest.Validate(); err != nil {
+		return Manifest{}, err
+	}
+	return b.manifest, nil
+}
@@ -0,0 +1,62 @@
+package artifacts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManifestBuilder(t *testing.T) {
+	manifest, err := NewManifestBuilder().
+		AddFile("data/a.txt", "digest-a", 3).
+		AddReference("data/b.txt", "s3://bucket/b.txt", 5).
+		Build()
+	assert.Nil(t, err)
+	assert.Equal(t, SupportedManifestVersion, manifest.Version)
+	assert.Equal(t, DefaultStoragePolicy, manifest.StoragePolicy)
+	assert.Nil(t, manifest.ValidateSchema())
+	assert.Len(t, manifest.Contents, 2)
+
+	a := manifest.Contents["data/a.txt"]
+	assert.False(t, a.IsReference())
+	assert.Equal(t, "digest-a", a.Digest)
+	assert.Equal(t, int64(3), a.Size)
+
+	b := manifest.Contents["data/b.txt"]
+	assert.True(t, b.IsReference())
+	assert.Equal(t, "s3://bucket/b.txt", *b.Ref)
+}
+
+func TestManifestBuilderRejectsInvalidEntries(t *testing.T) {
+	_, err := NewManifestBuilder().
+		AddFile("a.txt", "digest-a", 3).
+		AddFile("a.txt", "digest-b", 4).
+		AddFile("c.txt", "digest-c", 5).
+		Build()
+	assert.ErrorContains(t, err, `duplicate manifest entry "a.txt"`)
+
+	_, err = NewManifestBuilder().AddFile("a.txt", "", 3).Build()
+	assert.ErrorContains(t, err, "missing digest")
+
+	_, err = NewManifestBuilder().AddReference("a.txt", "s3://bucket/a", -1).Build()
+	assert.ErrorContains(t, err, "invalid size")
+
+	_, err = NewManifestBuilder().AddFile("", "digest", 1).Build()
+	assert.ErrorContains(t, err, "empty path")
+}
+
+func TestManifestBuilderAddDir(t *testing.T) {
+	manifest, err := NewManifestBuilder().
+		AddFile("data/a.txt", "digest-a", 3).
+		AddDir("data/empty").
+		AddDir("logs/").
+		Build()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"data/a.txt", "data/empty/", "logs/"}, manifest.SortedPaths())
+
+	for path, entry := range manifest.Contents {
+		assert.Equal(t, path != "data/a.txt", entry.IsDir(path), path)
+	}
+	assert.Equal(t, int64(3), manifest.TotalSize())
+	assert.Equal(t, int64(3), manifest.SizeExcludingReferences())
+}
@@ -0,0 +1,97 @@
+package artifacts
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// BundleManifestName is the name of the manifest file in a bundle written by
+// WriteBundle.
+const BundleManifestName = "wandb_manifest.json"
+
+// WriteBundle writes a tar archive to w containing the manifest, as
+// BundleManifestName, followed by the contents of each entry in path order.
+//
+// open is called to read each stored entry's contents, which must be exactly
+// the entry's size. Reference entries are skipped, since their contents are
+// not part of the artifact, directory placeholders are written as
+// directories, and symlink entries are written as symlinks.
+func (m *Manifest) WriteBundle(w io.Writer, open func(path string) (io.ReadCloser, error)) error {
+	tw := tar.NewWriter(w)
+
+	var manifest bytes.Buffer
+	if _, err := m.WriteTo(&manifest); err != nil {
+		return fmt.Errorf("error serializing manifest: %w", err)
+	}
+	header := &tar.Header{
+		Name: BundleManifestName,
+		Mode: 0644,
+		Size: int64(manifest.Len()),
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("error writing bundle: %w", err)
+	}
+	if _, err := tw.Write(manifest.Bytes()); err != nil {
+		return fmt.Errorf("error writing bundle: %w", err)
+	}
+
+	err := m.ForEachSorted(func(path string, entry ManifestEntry) error {
+		if entry.IsReference() {
+			return nil
+		}
+		if entry.IsDir(path) {
+			return tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeDir,
+				Name:     path,
+				Mode:     0755,
+			})
+		}
+		if entry.IsSymlink() {
+			return tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeSymlink,
+				Name:     path,
+				Linkname: *entry.SymlinkTarget,
+				Mode:     0777,
+			})
+		}
+		return writeBundleEntry(tw, path, entry, open)
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("error writing bundle: %w", err)
+	}
+	return nil
+}
+
+func writeBundleEntry(
+	tw *tar.Writer,
+	path string,
+	entry ManifestEntry,
+	open func(path string) (io.ReadCloser, error),
+) error {
+	r, err := open(path)
+	if err != nil {
+		return fmt.Errorf("error opening manifest entry %q: %w", path, err)
+	}
+	defer r.Close()
+
+	header := &tar.Header{Name: path, Mode: 0644, Size: entry.Size}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("error writing bundle: %w", err)
+	}
+	n, err := io.Copy(tw, r)
+	switch {
+	case errors.Is(err, tar.ErrWriteTooLong):
+		return fmt.Errorf("manifest entry %q is larger than its size %d", path, entry.Size)
+	case err != nil:
+		return fmt.Errorf("error writing manifest entry %q to bundle: %w", path, err)
+	case n < entry.Size:
+		return fmt.Errorf("manifest entry %q has %d bytes, expected %d", path, n, entry.Size)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package artifacts
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManifestWriteBundle(t *testing.T) {
+	files := map[string]string{
+		"a.txt":          "alpha",
+		"dir/b.txt":      "bravo!",
+		"dir/empty.txt":  "",
+		"reference.data": "never read",
+	}
+	ref, target := "s3://bucket/reference.data", "a.txt"
+	manifest := Manifest{
+		Version:       1,
+		StoragePolicy: DefaultStoragePolicy,
+		Contents: map[string]ManifestEntry{
+			"a.txt":          {Digest: "a", Size: 5},
+			"dir/b.txt":      {Digest: "b", Size: 6},
+			"dir/empty.txt":  {Digest: "e", Size: 0},
+			"link.txt":       {Digest: "a", Size: 5, SymlinkTarget: &target},
+			"reference.data": {Ref: &ref, Size: 10},
+		},
+	}
+	var opened []string
+	open := func(path string) (io.ReadCloser, error) {
+		opened = append(opened, path)
+		return io.NopCloser(strings.NewReader(files[path])), nil
+	}
+
+	var buf bytes.Buffer
+	assert.Nil(t, manifest.WriteBundle(&buf, open))
+	assert.Equal(t, []string{"a.txt", "dir/b.txt", "dir/empty.txt"}, opened)
+
+	tr := tar.NewReader(&buf)
+	var names []string
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+		names = append(names, header.Name)
+		data, err := io.ReadAll(tr)
+		assert.Nil(t, err)
+		switch header.Name {
+		case BundleManifestName:
+			var bundled Manifest
+			assert.Nil(t, json.Unmarshal(data, &bundled))
+			assert.Equal(t, manifest.Contents, bundled.Contents)
+		case "link.txt":
+			assert.Equal(t, byte(tar.TypeSymlink), header.Typeflag)
+			assert.Equal(t, target, header.Linkname)
+		default:
+			assert.Equal(t, manifest.Contents[header.Name].Size, header.Size)
+			assert.Equal(t, files[header.Name], string(data))
+		}
+	}
+	assert.Equal(t, []string{BundleManifestName, "a.txt", "dir/b.txt", "dir/empty.txt", "link.txt"}, names)
+}
+
+func TestManifestWriteBundleDirectories(t *testing.T) {
+	manifest, err := NewManifestBuilder().
+		AddFile("a.txt", "a", 5).
+		AddDir("empty").
+		Build()
+	assert.Nil(t, err)
+	open := func(path string) (io.ReadCloser, error) {
+		if path != "a.txt" {
+			return nil, fmt.Errorf("unexpected open of %s", path)
+		}
+		return io.NopCloser(strings.NewReader("alpha")), nil
+	}
+
+	var buf bytes.Buffer
+	assert.Nil(t, manifest.WriteBundle(&buf, open))
+
+	tr := tar.NewReader(&buf)
+	types := map[string]byte{}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+		types[header.Name] = header.Typeflag
+	}
+	assert.Equal(t, map[string]byte{
+		BundleManifestName: tar.TypeReg,
+		"a.txt":            tar.TypeReg,
+		"empty/":           tar.TypeDir,
+	}, types)
+}
+
+func TestManifestWriteBundleSizeMismatch(t *testing.T) {
+	for contents, expected := range map[string]string{
+		"too long": "larger than its size 5",
+		"shrt":     "has 4 bytes, expected 5",
+	} {
+		manifest := Manifest{Contents: map[string]ManifestEntry{"a.txt": {Digest: "a", Size: 5}}}
+		err := manifest.WriteBundle(io.Discard, func(path string) (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader(contents)), nil
+		})
+		assert.ErrorContains(t, err, expected)
+	}
+
+	manifest := Manifest{Contents: map[string]ManifestEntry{"a.txt": {Digest: "a", Size: 5}}}
+	err := manifest.WriteBundle(io.Discard, func(path string) (io.ReadCloser, error) {
+		return nil, fmt.Errorf("no such file")
+	})
+	assert.ErrorContains(t, err, "no such file")
+}
@@ -0,0 +1,69 @@
+package artifacts
+
+import (
+	"container/list"
+	"sync"
+)
+
+// ManifestCache is a least-recently-used cache of manifests keyed by digest.
+// It is safe for concurrent use.
+//
+// Cached manifests are shared between callers and must not be modified.
+type ManifestCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	order      *list.List // of *manifestCacheItem, most recent first
+	items      map[string]*list.Element
+}
+
+type manifestCacheItem struct {
+	digest   string
+	manifest *Manifest
+}
+
+// NewManifestCache returns a cache holding at most maxEntries manifests.
+// A maxEntries of zero or less means there is no limit.
+func NewManifestCache(maxEntries int) *ManifestCache {
+	return &ManifestCache{
+		maxEntries: maxEntries,
+		order:      list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+// Get returns the manifest cached under digest, if any.
+func (c *ManifestCache) Get(digest string) (*Manifest, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.items[digest]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*manifestCacheItem).manifest, true
+}
+
+// Put caches m under digest, evicting the least recently used manifest if the
+// cache is full.
+func (c *ManifestCache) Put(digest string, m *Manifest) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.items[digest]; ok {
+		element.Value.(*manifestCacheItem).manifest = m
+		c.order.MoveToFront(element)
+		return
+	}
+	c.items[digest] = c.order.PushFront(&manifestCacheItem{digest: digest, manifest: m})
+	if c.maxEntries > 0 && c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*manifestCacheItem).digest)
+	}
+}
+
+// Len returns the number of cached manifests.
+func (c *ManifestCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
@@ -0,0 +1,76 @@
+package artifacts
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wandb/wandb/nexus/pkg/utils"
+)
+
+func TestManifestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewManifestCache(2)
+	a, b, c := &Manifest{Version: 1}, &Manifest{Version: 2}, &Manifest{Version: 3}
+	cache.Put("a", a)
+	cache.Put("b", b)
+	_, ok := cache.Get("a")
+	assert.True(t, ok)
+	cache.Put("c", c)
+
+	assert.Equal(t, 2, cache.Len())
+	_, ok = cache.Get("b")
+	assert.False(t, ok)
+	got, ok := cache.Get("a")
+	assert.True(t, ok)
+	assert.Same(t, a, got)
+	got, ok = cache.Get("c")
+	assert.True(t, ok)
+	assert.Same(t, c, got)
+}
+
+func TestManifestLoaderCacheSkipsNetwork(t *testing.T) {
+	const body = `{"version":1,"contents":{"a.txt":{"digest":"x","size":1}}}`
+	digest, _ := utils.ComputeB64MD5([]byte(body))
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	loader := newTestManifestLoader()
+	loader.Cache = NewManifestCache(10)
+	for i := 0; i < 3; i++ {
+		manifest, err := loader.LoadFromURLWithDigest(context.Background(), server.URL, digest)
+		assert.Nil(t, err)
+		assert.Equal(t, "x", manifest.Contents["a.txt"].Digest)
+	}
+	assert.Equal(t, 1, requests)
+}
+
+func TestManifestLoaderCacheReturnsCopies(t *testing.T) {
+	const body = `{"version":1,"contents":{"a.txt":{"digest":"x","size":1}}}`
+	digest, _ := utils.ComputeB64MD5([]byte(body))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	loader := newTestManifestLoader()
+	loader.Cache = NewManifestCache(10)
+	first, err := loader.LoadFromURLWithDigest(context.Background(), server.URL, digest)
+	assert.Nil(t, err)
+	first.Contents["b.txt"] = ManifestEntry{Digest: "y"}
+
+	second, err := loader.LoadFromURLWithDigest(context.Background(), server.URL, digest)
+	assert.Nil(t, err)
+	assert.Len(t, second.Contents, 1)
+	delete(second.Contents, "a.txt")
+
+	third, err := loader.LoadFromURLWithDigest(context.Background(), server.URL, digest)
+	assert.Nil(t, err)
+	assert.Equal(t, "x", third.Contents["a.txt"].Digest)
+	assert.Len(t, third.Contents, 1)
+}
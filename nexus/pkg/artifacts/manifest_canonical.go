@@ -0,0 +1,101 @@
+package artifacts
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/wandb/wandb/nexus/pkg/utils"
+)
+
+// MarshalCanonical returns a canonical JSON encoding of the manifest, suitable
+// for computing a digest that is reproducible across clients.
+//
+// The manifest is encoded as normalized by Normalize, without modifying m.
+// Object keys are sorted at every level of nesting, including inside entries'
+// Extra values, numbers are emitted exactly as they were decoded, and HTML
+// characters are not escaped.
+func (m *Manifest) MarshalCanonical() ([]byte, error) {
+	normalized := copyManifest(*m)
+	normalized.Normalize()
+	return normalized.marshalSorted("")
+}
+
+// Normalize rewrites equivalent encodings that different producers emit into
+// a single form, so that logically identical manifests have identical
+// canonical encodings and digests: empty Extra maps become nil, empty Ref
+// strings become nil, and a nil Contents becomes an empty map.
+func (m *Manifest) Normalize() {
+	if m.Contents == nil {
+		m.Contents = make(map[string]ManifestEntry)
+	}
+	for path, entry := range m.Contents {
+		if len(entry.Extra) == 0 {
+			entry.Extra = nil
+		}
+		if entry.Ref != nil && *entry.Ref == "" {
+			entry.Ref = nil
+		}
+		m.Contents[path] = entry
+	}
+}
+
+// IdentityDigest returns a base64-encoded MD5 digest that identifies the
+// logical contents of the manifest: two manifests have the same identity
+// digest exactly when their canonical encodings match.
+//
+// DownloadURL and LocalPath vary between fetches and machines, so they are
+// cleared before hashing. They are not serialized today anyway, but clearing
+// them explicitly keeps the digest stable if that ever changes.
+func (m *Manifest) IdentityDigest() (string, error) {
+	identity := *m
+	identity.Contents = make(map[string]ManifestEntry, len(m.Contents))
+	for path, entry := range m.Contents {
+		entry.DownloadURL = nil
+		entry.LocalPath = nil
+		identity.Contents[path] = entry
+	}
+	data, err := identity.MarshalCanonical()
+	if err != nil {
+		return "", err
+	}
+	return utils.ComputeB64MD5(data)
+}
+
+// MarshalIndent returns an indented, human-readable JSON encoding of the
+// manifest with keys sorted as in MarshalCanonical, for debugging.
+//
+// The output is NOT suitable for computing a manifest digest: the backend
+// computes digests over the compact encoding written by WriteToFile.
+func (m *Manifest) MarshalIndent() ([]byte, error) {
+	return m.marshalSorted("  ")
+}
+
+// marshalSorted encodes the manifest with sorted keys, indenting nested
+// values by indent if it is non-empty.
+func (m *Manifest) marshalSorted(indent string) ([]byte, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+
+	// Round-tripping through interface{} turns every nested object into a
+	// map, which encoding/json always writes with sorted keys.
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var generic interface{}
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, fmt.Errorf("error canonicalizing manifest: %w", err)
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if indent != "" {
+		encoder.SetIndent("", indent)
+	}
+	if err := encoder.Encode(generic); err != nil {
+		return nil, fmt.Errorf("error canonicalizing manifest: %w", err)
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
@@ -0,0 +1,156 @@
+package artifacts
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wandb/wandb/nexus/pkg/utils"
+)
+
+func TestMarshalCanonicalIgnoresInsertionOrder(t *testing.T) {
+	newManifest := func(keys []string) Manifest {
+		extra := map[string]interface{}{}
+		nested := map[string]interface{}{}
+		for i, key := range keys {
+			extra[key] = i
+			nested[key] = []interface{}{key, map[string]interface{}{key: true, "z": 1.5}}
+		}
+		extra["nested"] = nested
+		return Manifest{
+			Version:       1,
+			StoragePolicy: "wandb-storage-policy-v1",
+			Contents: map[string]ManifestEntry{
+				"a.txt": {Digest: "abc", Size: 1, Extra: extra},
+			},
+		}
+	}
+
+	first := newManifest([]string{"b", "a", "c", "<tag>"})
+	second := newManifest([]string{"<tag>", "c", "a", "b"})
+	for key := range second.Contents["a.txt"].Extra {
+		// Make the values themselves match; only insertion order differs.
+		second.Contents["a.txt"].Extra[key] = first.Contents["a.txt"].Extra[key]
+	}
+
+	firstBytes, err := first.MarshalCanonical()
+	assert.Nil(t, err)
+	secondBytes, err := second.MarshalCanonical()
+	assert.Nil(t, err)
+	assert.Equal(t, string(firstBytes), string(secondBytes))
+	assert.Contains(t, string(firstBytes), `"<tag>"`)
+
+	firstDigest, _ := utils.ComputeB64MD5(firstBytes)
+	secondDigest, _ := utils.ComputeB64MD5(secondBytes)
+	assert.Equal(t, firstDigest, secondDigest)
+}
+
+func TestMarshalCanonicalPreservesNumbers(t *testing.T) {
+	manifest := Manifest{
+		Contents: map[string]ManifestEntry{
+			"a.txt": {Digest: "abc", Size: 9007199254740993},
+		},
+	}
+	data, err := manifest.MarshalCanonical()
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `"size":9007199254740993`)
+}
+
+func TestManifestMarshalIndent(t *testing.T) {
+	manifest, err := parseManifest([]byte(`{
+		"version": 1,
+		"storagePolicy": "wandb-storage-policy-v1",
+		"storagePolicyConfig": {"storageLayout": "V2"},
+		"contents": {
+			"b.txt": {"digest": "b", "birthArtifactID": "id", "size": 2, "extra": {"z": 1, "a": [true, "<x>"]}},
+			"a.txt": {"digest": "a", "birthArtifactID": null, "ref": "s3://bucket/a", "size": 1}
+		}
+	}`))
+	assert.Nil(t, err)
+
+	data, err := manifest.MarshalIndent()
+	assert.Nil(t, err)
+	assert.True(t, json.Valid(data))
+	assert.Contains(t, string(data), "\n  \"contents\": {\n    \"a.txt\": {")
+	assert.Less(t, strings.Index(string(data), `"a": [`), strings.Index(string(data), `"z": 1`))
+
+	var roundTripped Manifest
+	assert.Nil(t, json.Unmarshal(data, &roundTripped))
+	assert.Equal(t, manifest, roundTripped)
+}
+
+func TestManifestIdentityDigest(t *testing.T) {
+	newManifest := func(downloadURL, localPath string) Manifest {
+		return Manifest{
+			Version:       1,
+			StoragePolicy: "wandb-storage-policy-v1",
+			Contents: map[string]ManifestEntry{
+				"a.txt": {Digest: "abc", Size: 1, DownloadURL: &downloadURL, LocalPath: &localPath},
+			},
+		}
+	}
+	first := newManifest("https://example.com/a?sig=1", "/tmp/one/a.txt")
+	second := newManifest("https://mirror.example.com/a?sig=2", "/tmp/two/a.txt")
+
+	firstDigest, err := first.IdentityDigest()
+	assert.Nil(t, err)
+	secondDigest, err := second.IdentityDigest()
+	assert.Nil(t, err)
+	assert.Equal(t, firstDigest, secondDigest)
+	assert.NotNil(t, first.Contents["a.txt"].DownloadURL)
+
+	changed := newManifest("https://example.com/a?sig=1", "/tmp/one/a.txt")
+	changed.Contents["a.txt"] = ManifestEntry{Digest: "abd", Size: 1}
+	changedDigest, err := changed.IdentityDigest()
+	assert.Nil(t, err)
+	assert.NotEqual(t, firstDigest, changedDigest)
+}
+
+func TestManifestNormalize(t *testing.T) {
+	emptyRef, ref, otherRef := "", "s3://bucket/key", "s3://bucket/key"
+	// The same manifest as written by two producers.
+	first := Manifest{
+		Version:       1,
+		StoragePolicy: "wandb-storage-policy-v1",
+		Contents: map[string]ManifestEntry{
+			"a.txt":   {Digest: "a", Size: 1, Extra: map[string]interface{}{}, Ref: &emptyRef},
+			"ref.txt": {Digest: "etag", Size: 2, Ref: &ref, Extra: map[string]interface{}{"x": 1.0}},
+		},
+	}
+	second := Manifest{
+		Version:       1,
+		StoragePolicy: "wandb-storage-policy-v1",
+		Contents: map[string]ManifestEntry{
+			"a.txt":   {Digest: "a", Size: 1},
+			"ref.txt": {Digest: "etag", Size: 2, Ref: &otherRef, Extra: map[string]interface{}{"x": 1.0}},
+		},
+	}
+
+	firstBytes, err := first.MarshalCanonical()
+	assert.Nil(t, err)
+	secondBytes, err := second.MarshalCanonical()
+	assert.Nil(t, err)
+	assert.Equal(t, string(secondBytes), string(firstBytes))
+	firstDigest, err := first.IdentityDigest()
+	assert.Nil(t, err)
+	secondDigest, err := second.IdentityDigest()
+	assert.Nil(t, err)
+	assert.Equal(t, secondDigest, firstDigest)
+	assert.NotNil(t, first.Contents["a.txt"].Ref, "MarshalCanonical must not modify the manifest")
+
+	first.Normalize()
+	second.Normalize()
+	assert.Equal(t, second, first)
+	assert.Nil(t, first.Contents["a.txt"].Ref)
+	assert.Nil(t, first.Contents["a.txt"].Extra)
+	assert.Equal(t, ref, *first.Contents["ref.txt"].Ref)
+
+	withNilContents := Manifest{Version: 1}
+	withEmptyContents := Manifest{Version: 1, Contents: map[string]ManifestEntry{}}
+	nilBytes, err := withNilContents.MarshalCanonical()
+	assert.Nil(t, err)
+	emptyBytes, err := withEmptyContents.MarshalCanonical()
+	assert.Nil(t, err)
+	assert.Equal(t, string(emptyBytes), string(nilBytes))
+}
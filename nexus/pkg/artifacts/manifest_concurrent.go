@@ -0,0 +1,66 @@
+package artifacts
+
+import "sync"
+
+// ConcurrentManifest is a Manifest that is safe to modify concurrently, such
+// as when entries are added while an artifact's files are hashed in
+// parallel.
+type ConcurrentManifest struct {
+	mu       sync.RWMutex
+	manifest Manifest
+}
+
+// NewConcurrentManifest returns a ConcurrentManifest holding a copy of m.
+func NewConcurrentManifest(m Manifest) *ConcurrentManifest {
+	return &ConcurrentManifest{manifest: copyManifest(m)}
+}
+
+// Set adds or replaces the entry at path.
+func (c *ConcurrentManifest) Set(path string, entry ManifestEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.manifest.Contents[path] = entry
+}
+
+// Get returns the entry at path, and false if there is none.
+func (c *ConcurrentManifest) Get(path string) (ManifestEntry, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	entry, ok := c.manifest.Contents[path]
+	return entry, ok
+}
+
+// Delete removes the entry at path, if any.
+func (c *ConcurrentManifest) Delete(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.manifest.Contents, path)
+}
+
+// Len returns the number of entries.
+func (c *ConcurrentManifest) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.manifest.Contents)
+}
+
+// Snapshot returns a copy of the manifest that is unaffected by later
+// changes to c.
+//
+// Entries are copied by value, so their Extra maps and slices are shared
+// with c and must not be modified.
+func (c *ConcurrentManifest) Snapshot() Manifest {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return copyManifest(c.manifest)
+}
+
+// copyManifest returns m with a copy of its Contents map, which is never nil.
+func copyManifest(m Manifest) Manifest {
+	contents := make(map[string]ManifestEntry, len(m.Contents))
+	for path, entry := range m.Contents {
+		contents[path] = entry
+	}
+	m.Contents = contents
+	return m
+}
@@ -0,0 +1,54 @@
+package artifacts
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConcurrentManifest(t *testing.T) {
+	const goroutines, entriesPerGoroutine = 16, 200
+	base := Manifest{
+		Version:       1,
+		StoragePolicy: DefaultStoragePolicy,
+		Contents:      map[string]ManifestEntry{"existing.txt": {Digest: "x"}},
+	}
+	c := NewConcurrentManifest(base)
+
+	snapshots := make(chan Manifest, goroutines)
+	wg := sync.WaitGroup{}
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < entriesPerGoroutine; i++ {
+				path := fmt.Sprintf("dir-%d/file-%d.txt", g, i)
+				c.Set(path, ManifestEntry{Digest: path, Size: int64(i)})
+				entry, ok := c.Get(path)
+				assert.True(t, ok)
+				assert.Equal(t, path, entry.Digest)
+				_ = c.Len()
+			}
+			c.Delete("existing.txt")
+			snapshots <- c.Snapshot()
+		}(g)
+	}
+	wg.Wait()
+	close(snapshots)
+
+	for snapshot := range snapshots {
+		assert.Equal(t, base.StoragePolicy, snapshot.StoragePolicy)
+		assert.NotContains(t, snapshot.Contents, "existing.txt")
+	}
+	final := c.Snapshot()
+	assert.Len(t, final.Contents, goroutines*entriesPerGoroutine)
+	assert.Equal(t, goroutines*entriesPerGoroutine, c.Len())
+
+	// Neither the original nor a snapshot shares the wrapper's map.
+	assert.Len(t, base.Contents, 1)
+	final.Contents["extra.txt"] = ManifestEntry{Digest: "y"}
+	_, ok := c.Get("extra.txt")
+	assert.False(t, ok)
+}
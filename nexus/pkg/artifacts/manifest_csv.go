@@ -0,0 +1,41 @@
+package artifacts
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/wandb/wandb/nexus/pkg/utils"
+)
+
+// csvHeader is the first row written by WriteCSV.
+var csvHeader = []string{"path", "digest", "size", "ref"}
+
+// WriteCSV writes a CSV listing of the manifest's entries to w, for audits:
+// a header row, then the path, digest, size and reference of each entry in
+// sorted path order. The reference is empty for stored entries. Fields
+// containing commas, quotes or newlines are quoted.
+func (m *Manifest) WriteCSV(w io.Writer) error {
+	csvWriter := csv.NewWriter(w)
+	if err := csvWriter.Write(csvHeader); err != nil {
+		return fmt.Errorf("error writing manifest CSV: %w", err)
+	}
+	for _, path := range m.SortedPaths() {
+		entry := m.Contents[path]
+		record := []string{
+			path,
+			entry.Digest,
+			strconv.FormatInt(entry.Size, 10),
+			utils.ZeroIfNil(entry.Ref),
+		}
+		if err := csvWriter.Write(record); err != nil {
+			return fmt.Errorf("error writing manifest CSV: %w", err)
+		}
+	}
+	csvWriter.Flush()
+	if err := csvWriter.Error(); err != nil {
+		return fmt.Errorf("error writing manifest CSV: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package artifacts
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManifestWriteCSV(t *testing.T) {
+	ref := "s3://bucket/key"
+	manifest := Manifest{
+		Version: 1,
+		Contents: map[string]ManifestEntry{
+			"b.txt":             {Digest: "b", Size: 2},
+			"a, with comma.txt": {Digest: "a", Size: 1},
+			"ref.txt":           {Ref: &ref, Digest: "etag", Size: 3},
+		},
+	}
+
+	var buf bytes.Buffer
+	assert.Nil(t, manifest.WriteCSV(&buf))
+	assert.Equal(t,
+		"path,digest,size,ref\n"+
+			"\"a, with comma.txt\",a,1,\n"+
+			"b.txt,b,2,\n"+
+			"ref.txt,etag,3,s3://bucket/key\n",
+		buf.String(),
+	)
+}
+
+func TestManifestWriteCSVEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Nil(t, (&Manifest{}).WriteCSV(&buf))
+	assert.Equal(t, "path,digest,size,ref\n", buf.String())
+}
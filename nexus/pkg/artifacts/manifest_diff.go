@@ -0,0 +1,149 @@
+package artifacts
+
+import (
+	"slices"
+	"sort"
+)
+
+// ManifestDiff describes how one manifest differs from another.
+type ManifestDiff struct {
+	// Added contains the entries that are only in the other manifest.
+	Added map[string]ManifestEntry
+
+	// Removed contains the entries that are only in the original manifest.
+	Removed map[string]ManifestEntry
+
+	// Changed contains the entries present in both manifests whose digests
+	// differ.
+	Changed map[string]ManifestEntryChange
+}
+
+// ManifestEntryChange is an entry whose contents differ between two manifests.
+type ManifestEntryChange struct {
+	Old ManifestEntry
+	New ManifestEntry
+}
+
+// IsEmpty reports whether the two manifests had identical contents.
+func (d *ManifestDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Diff returns the changes needed to go from m to other.
+//
+// Entries are compared by digest only; an entry whose size or metadata
+// changed but whose digest did not is considered unchanged.
+func (m *Manifest) Diff(other *Manifest) ManifestDiff {
+	diff := ManifestDiff{
+		Added:   make(map[string]ManifestEntry),
+		Removed: make(map[string]ManifestEntry),
+		Changed: make(map[string]ManifestEntryChange),
+	}
+	for path, entry := range m.Contents {
+		otherEntry, ok := other.Contents[path]
+		switch {
+		case !ok:
+			diff.Removed[path] = entry
+		case otherEntry.Digest != entry.Digest:
+			diff.Changed[path] = ManifestEntryChange{Old: entry, New: otherEntry}
+		}
+	}
+	for path, entry := range other.Contents {
+		if _, ok := m.Contents[path]; !ok {
+			diff.Added[path] = entry
+		}
+	}
+	return diff
+}
+
+// Equal reports whether m and other serialize to equivalent JSON: they have
+// the same version, storage policy and entries, with every serialized entry
+// field equal. LocalPath and DownloadURL are not serialized, so they are
+// ignored, and nil and empty maps and slices are equal.
+func (m *Manifest) Equal(other *Manifest) bool {
+	if m.Version != other.Version ||
+		m.StoragePolicy != other.StoragePolicy ||
+		m.StoragePolicyConfig != other.StoragePolicyConfig ||
+		len(m.Contents) != len(other.Contents) {
+		return false
+	}
+	for path, entry := range m.Contents {
+		otherEntry, ok := other.Contents[path]
+		if !ok || !entry.EqualContent(otherEntry) ||
+			!equalStringPointers(entry.BirthArtifactID, otherEntry.BirthArtifactID) ||
+			// EqualContent treats a nil ContentEncoding like an empty one,
+			// but they serialize differently.
+			!equalStringPointers(entry.ContentEncoding, otherEntry.ContentEncoding) ||
+			!slices.Equal(entry.Chunks, otherEntry.Chunks) ||
+			!slices.Equal(entry.Tags, otherEntry.Tags) {
+			return false
+		}
+	}
+	return true
+}
+
+// equalStringPointers reports whether a and b are both nil or point to equal
+// strings.
+func equalStringPointers(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// RenameAwareDiff is a ManifestDiff in which entries that moved to a new path
+// without changing are reported as renames rather than as a removal and an
+// addition.
+type RenameAwareDiff struct {
+	ManifestDiff
+
+	// Renamed maps each renamed entry's old path to its new path.
+	Renamed map[string]string
+}
+
+// IsEmpty reports whether the two manifests had identical contents.
+func (d *RenameAwareDiff) IsEmpty() bool {
+	return d.ManifestDiff.IsEmpty() && len(d.Renamed) == 0
+}
+
+// DiffWithRenames is like Diff, but reports a removed entry and an added
+// entry with the same digest as a rename.
+//
+// If several removed and added entries share a digest, they are paired up in
+// path order, and any left over are reported as removed or added.
+func (m *Manifest) DiffWithRenames(other *Manifest) RenameAwareDiff {
+	diff := RenameAwareDiff{
+		ManifestDiff: m.Diff(other),
+		Renamed:      make(map[string]string),
+	}
+
+	addedByDigest := make(map[string][]string)
+	for _, path := range sortedKeys(diff.Added) {
+		if digest := diff.Added[path].Digest; digest != "" {
+			addedByDigest[digest] = append(addedByDigest[digest], path)
+		}
+	}
+	for _, oldPath := range sortedKeys(diff.Removed) {
+		digest := diff.Removed[oldPath].Digest
+		candidates := addedByDigest[digest]
+		if digest == "" || len(candidates) == 0 {
+			continue
+		}
+		newPath := candidates[0]
+		addedByDigest[digest] = candidates[1:]
+		diff.Renamed[oldPath] = newPath
+		delete(diff.Removed, oldPath)
+		delete(diff.Added, newPath)
+	}
+	return diff
+}
+
+// sortedKeys returns the keys of entries in lexicographic order.
+func sortedKeys(entries map[string]ManifestEntry) []string {
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,140 @@
+package artifacts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManifestDiff(t *testing.T) {
+	old := Manifest{
+		Contents: map[string]ManifestEntry{
+			"same.txt":    {Digest: "a", Size: 1},
+			"changed.txt": {Digest: "b", Size: 1},
+			"removed.txt": {Digest: "c", Size: 1},
+			"resized.txt": {Digest: "d", Size: 1},
+		},
+	}
+	new := Manifest{
+		Contents: map[string]ManifestEntry{
+			"same.txt":    {Digest: "a", Size: 1},
+			"changed.txt": {Digest: "B", Size: 1},
+			"added.txt":   {Digest: "e", Size: 1},
+			"resized.txt": {Digest: "d", Size: 2},
+		},
+	}
+
+	diff := old.Diff(&new)
+	assert.Equal(t, map[string]ManifestEntry{"added.txt": {Digest: "e", Size: 1}}, diff.Added)
+	assert.Equal(t, map[string]ManifestEntry{"removed.txt": {Digest: "c", Size: 1}}, diff.Removed)
+	assert.Equal(t,
+		map[string]ManifestEntryChange{
+			"changed.txt": {Old: ManifestEntry{Digest: "b", Size: 1}, New: ManifestEntry{Digest: "B", Size: 1}},
+		},
+		diff.Changed,
+	)
+	assert.False(t, diff.IsEmpty())
+}
+
+func TestManifestDiffIdentical(t *testing.T) {
+	manifest := Manifest{
+		Contents: map[string]ManifestEntry{
+			"a.txt": {Digest: "a", Size: 1},
+		},
+	}
+	diff := manifest.Diff(&manifest)
+	assert.True(t, diff.IsEmpty())
+}
+
+func TestManifestDiffWithRenames(t *testing.T) {
+	old := Manifest{
+		Contents: map[string]ManifestEntry{
+			"same.txt":       {Digest: "a", Size: 1},
+			"old/model.pt":   {Digest: "m", Size: 10},
+			"changed.txt":    {Digest: "b", Size: 1},
+			"removed.txt":    {Digest: "c", Size: 1},
+			"copies/one.txt": {Digest: "dup", Size: 1},
+			"copies/two.txt": {Digest: "dup", Size: 1},
+		},
+	}
+	new := Manifest{
+		Contents: map[string]ManifestEntry{
+			"same.txt":     {Digest: "a", Size: 1},
+			"new/model.pt": {Digest: "m", Size: 10},
+			"changed.txt":  {Digest: "B", Size: 1},
+			"added.txt":    {Digest: "e", Size: 1},
+			"moved/1.txt":  {Digest: "dup", Size: 1},
+		},
+	}
+
+	diff := old.DiffWithRenames(&new)
+	assert.Equal(t, map[string]string{
+		"old/model.pt":   "new/model.pt",
+		"copies/one.txt": "moved/1.txt",
+	}, diff.Renamed)
+	assert.Equal(t, map[string]ManifestEntry{"added.txt": {Digest: "e", Size: 1}}, diff.Added)
+	assert.Equal(t, map[string]ManifestEntry{
+		"removed.txt":    {Digest: "c", Size: 1},
+		"copies/two.txt": {Digest: "dup", Size: 1},
+	}, diff.Removed)
+	assert.Len(t, diff.Changed, 1)
+	assert.Equal(t, "B", diff.Changed["changed.txt"].New.Digest)
+	assert.False(t, diff.IsEmpty())
+
+	renameOnly := Manifest{Contents: map[string]ManifestEntry{"a.txt": {Digest: "a"}}}
+	renamed := Manifest{Contents: map[string]ManifestEntry{"b.txt": {Digest: "a"}}}
+	renameDiff := renameOnly.DiffWithRenames(&renamed)
+	assert.True(t, renameDiff.ManifestDiff.IsEmpty())
+	assert.False(t, renameDiff.IsEmpty())
+	identical := renameOnly.DiffWithRenames(&renameOnly)
+	assert.True(t, identical.IsEmpty())
+}
+
+func TestManifestEqual(t *testing.T) {
+	birth, ref, encoding, empty := "birth", "s3://bucket/key", "gzip", ""
+	newManifest := func() Manifest {
+		return Manifest{
+			Version:             1,
+			StoragePolicy:       "wandb-storage-policy-v1",
+			StoragePolicyConfig: StoragePolicyConfig{StorageLayout: StorageLayoutV2},
+			Contents: map[string]ManifestEntry{
+				"a.txt":   {Digest: "a", Size: 1, BirthArtifactID: &birth, ContentEncoding: &encoding},
+				"ref.txt": {Digest: "etag", Ref: &ref, Tags: []string{"x"}},
+			},
+		}
+	}
+	m, other := newManifest(), newManifest()
+	assert.True(t, m.Equal(&other))
+
+	localPath := "/tmp/a.txt"
+	entry := other.Contents["a.txt"]
+	entry.LocalPath = &localPath
+	entry.Chunks = []ManifestChunk{}
+	other.Contents["a.txt"] = entry
+	assert.True(t, m.Equal(&other), "unserialized fields and empty slices are ignored")
+
+	for name, modify := range map[string]func(*Manifest){
+		"version":        func(m *Manifest) { m.Version = 2 },
+		"storage layout": func(m *Manifest) { m.StoragePolicyConfig.StorageLayout = StorageLayoutV1 },
+		"missing entry":  func(m *Manifest) { delete(m.Contents, "a.txt") },
+		"birth artifact": func(m *Manifest) {
+			entry := m.Contents["a.txt"]
+			entry.BirthArtifactID = nil
+			m.Contents["a.txt"] = entry
+		},
+		"empty content encoding": func(m *Manifest) {
+			entry := m.Contents["a.txt"]
+			entry.ContentEncoding = &empty
+			m.Contents["a.txt"] = entry
+		},
+		"tags": func(m *Manifest) {
+			entry := m.Contents["ref.txt"]
+			entry.Tags = []string{"y"}
+			m.Contents["ref.txt"] = entry
+		},
+	} {
+		other := newManifest()
+		modify(&other)
+		assert.False(t, m.Equal(&other), name)
+	}
+}
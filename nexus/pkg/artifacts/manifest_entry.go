@@ -0,0 +1,307 @@
+package artifacts
+
+import (
+	"compress/gzip"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/wandb/wandb/nexus/pkg/utils"
+)
+
+// Validate checks that the entry's fields are internally consistent.
+//
+// A stored (non-reference) entry must have a digest, no entry may have a
+// negative size, and an entry may not be both a reference and a stored file.
+func (e *ManifestEntry) Validate() error {
+	if e.Ref == nil && e.Digest == "" {
+		return fmt.Errorf("missing digest")
+	}
+	if e.isReferenceAndStored() {
+		return fmt.Errorf("entry is both a reference and a stored file")
+	}
+	if e.Size < 0 {
+		return fmt.Errorf("invalid size %d", e.Size)
+	}
+	return nil
+}
+
+// ValidateMaterializable is like Validate, but additionally requires a
+// stored entry to have a LocalPath or DownloadURL from which its contents can
+// be obtained.
+//
+// Reference entries are fetched by the user process, so they are exempt.
+func (e *ManifestEntry) ValidateMaterializable() error {
+	if err := e.Validate(); err != nil {
+		return err
+	}
+	if e.Ref == nil && e.LocalPath == nil && e.DownloadURL == nil {
+		return fmt.Errorf("neither local path nor download URL is set")
+	}
+	return nil
+}
+
+// IsReference reports whether the entry refers to an object stored outside
+// of W&B rather than a file uploaded with the artifact.
+func (e *ManifestEntry) IsReference() bool {
+	return e.Ref != nil
+}
+
+// IsDir reports whether the entry stored at path is a placeholder for a
+// directory, which lets a manifest record empty directories. By convention,
+// such an entry has a path ending in "/" and a size of zero.
+func (e *ManifestEntry) IsDir(path string) bool {
+	return strings.HasSuffix(path, "/") && e.Size == 0 && e.Ref == nil
+}
+
+// IsSymlink reports whether the entry records a symbolic link rather than a
+// regular file.
+func (e *ManifestEntry) IsSymlink() bool {
+	return e.SymlinkTarget != nil
+}
+
+// isReferenceAndStored reports whether the entry has both a Ref and a
+// DownloadURL for a digest in W&B storage. These storage modes are mutually
+// exclusive, so such an entry is the result of a bad merge.
+func (e *ManifestEntry) isReferenceAndStored() bool {
+	return e.Ref != nil && e.Digest != "" && e.DownloadURL != nil
+}
+
+// IsCompressed reports whether the entry's contents are stored with a
+// ContentEncoding, such as "gzip", that must be undone after download.
+func (e *ManifestEntry) IsCompressed() bool {
+	return e.ContentEncoding != nil && *e.ContentEncoding != "" && *e.ContentEncoding != "identity"
+}
+
+// DecodeContent returns a reader of the entry's original contents given r,
+// a reader of its contents as stored. Only the "gzip" encoding is supported.
+func (e *ManifestEntry) DecodeContent(r io.Reader) (io.Reader, error) {
+	if !e.IsCompressed() {
+		return r, nil
+	}
+	switch *e.ContentEncoding {
+	case "gzip":
+		gzipReader, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("error decompressing manifest entry: %w", err)
+		}
+		return gzipReader, nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", *e.ContentEncoding)
+	}
+}
+
+// IsEncrypted reports whether the entry's contents are encrypted at rest and
+// must be decrypted, as described by Encryption, after download.
+func (e *ManifestEntry) IsEncrypted() bool {
+	return e.Encryption != nil && e.Encryption.Algorithm != ""
+}
+
+// RefScheme returns the URI scheme of a reference entry, such as "s3" or
+// "gs".
+func (e *ManifestEntry) RefScheme() (string, error) {
+	if e.Ref == nil {
+		return "", fmt.Errorf("manifest entry is not a reference")
+	}
+	refURL, err := url.Parse(*e.Ref)
+	if err != nil {
+		return "", fmt.Errorf("invalid reference %q: %w", *e.Ref, err)
+	}
+	if refURL.Scheme == "" {
+		return "", fmt.Errorf("reference %q has no scheme", *e.Ref)
+	}
+	return refURL.Scheme, nil
+}
+
+// ExtraString returns the string stored under key in Extra.
+//
+// The second return value is false if the key is missing or its value is not
+// a string.
+func (e *ManifestEntry) ExtraString(key string) (string, bool) {
+	value, ok := e.Extra[key].(string)
+	return value, ok
+}
+
+// ExtraInt64 returns the integer stored under key in Extra.
+//
+// Values decoded from JSON are float64s (or json.Numbers), so these are
+// accepted as long as they hold a whole number that fits in an int64. The
+// second return value is false otherwise, or if the key is missing.
+func (e *ManifestEntry) ExtraInt64(key string) (int64, bool) {
+	switch value := e.Extra[key].(type) {
+	case int64:
+		return value, true
+	case int:
+		return int64(value), true
+	case int32:
+		return int64(value), true
+	case float64:
+		if value != math.Trunc(value) || value < math.MinInt64 || value >= math.MaxInt64 {
+			return 0, false
+		}
+		return int64(value), true
+	case json.Number:
+		n, err := value.Int64()
+		return n, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// ContentType returns the MIME type of the entry stored at path: the string
+// in Extra["contentType"] if there is one, and otherwise the type registered
+// for path's extension, or "application/octet-stream" if there is none.
+func (e *ManifestEntry) ContentType(path string) string {
+	if contentType, ok := e.ExtraString("contentType"); ok && contentType != "" {
+		return contentType
+	}
+	if contentType := mime.TypeByExtension(filepath.Ext(path)); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+// VerifyChunks checks each of the entry's chunks against the corresponding
+// bytes of r, which holds the entry's full contents. Chunk digests are
+// base64-encoded MD5s, like entry digests.
+func (e *ManifestEntry) VerifyChunks(r io.ReaderAt) error {
+	for i, chunk := range e.Chunks {
+		if chunk.Offset < 0 || chunk.Size < 0 || chunk.Offset+chunk.Size > e.Size {
+			return fmt.Errorf(
+				"chunk %d (offset %d, size %d) is out of bounds for entry of size %d",
+				i, chunk.Offset, chunk.Size, e.Size,
+			)
+		}
+		hasher := md5.New()
+		section := io.NewSectionReader(r, chunk.Offset, chunk.Size)
+		n, err := io.Copy(hasher, section)
+		if err != nil {
+			return fmt.Errorf("error reading chunk %d: %w", i, err)
+		}
+		if n != chunk.Size {
+			return fmt.Errorf("chunk %d: expected %d bytes, read %d", i, chunk.Size, n)
+		}
+		digest := base64.StdEncoding.EncodeToString(hasher.Sum(nil))
+		if digest != chunk.Digest {
+			return fmt.Errorf(
+				"chunk %d %w: expected %s, got %s",
+				i, ErrDigestMismatch, chunk.Digest, digest,
+			)
+		}
+	}
+	return nil
+}
+
+// ResolveRef returns the entry's reference resolved against base, following
+// RFC 3986. Absolute references are returned unchanged.
+//
+// For example, a Ref of "data/file.csv" resolves against
+// "s3://bucket/prefix/" to "s3://bucket/prefix/data/file.csv".
+func (e *ManifestEntry) ResolveRef(base string) (string, error) {
+	if e.Ref == nil {
+		return "", fmt.Errorf("manifest entry is not a reference")
+	}
+	refURL, err := url.Parse(*e.Ref)
+	if err != nil {
+		return "", fmt.Errorf("invalid reference %q: %w", *e.Ref, err)
+	}
+	if refURL.IsAbs() {
+		return *e.Ref, nil
+	}
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return "", fmt.Errorf("invalid base URI %q: %w", base, err)
+	}
+	if !baseURL.IsAbs() {
+		return "", fmt.Errorf("base URI %q is not absolute", base)
+	}
+	return baseURL.ResolveReference(refURL).String(), nil
+}
+
+// DigestKind identifies the format of a digest.
+type DigestKind int
+
+const (
+	DigestKindUnknown DigestKind = iota
+	// DigestKindMD5Base64 is a base64-encoded MD5, used for files stored by
+	// W&B.
+	DigestKindMD5Base64
+	// DigestKindMD5Hex is a hex-encoded MD5, such as a single-part S3 ETag.
+	DigestKindMD5Hex
+	// DigestKindSHA256Hex is a hex-encoded SHA256.
+	DigestKindSHA256Hex
+	// DigestKindMultipartETag is an S3 multipart ETag: the hex MD5 of the
+	// concatenated part MD5s, a dash, and the number of parts.
+	DigestKindMultipartETag
+)
+
+// DigestKind infers the format of the entry's digest from its length and
+// character set. Surrounding quotes, as found in raw ETags, are ignored.
+func (e *ManifestEntry) DigestKind() DigestKind {
+	digest := strings.Trim(e.Digest, `"`)
+	if hash, parts, ok := strings.Cut(digest, "-"); ok {
+		n, err := strconv.Atoi(parts)
+		if err == nil && n > 0 && len(hash) == 32 && isHex(hash) {
+			return DigestKindMultipartETag
+		}
+		return DigestKindUnknown
+	}
+	switch {
+	case len(digest) == 32 && isHex(digest):
+		return DigestKindMD5Hex
+	case len(digest) == 64 && isHex(digest):
+		return DigestKindSHA256Hex
+	case len(digest) == 24:
+		if decoded, err := base64.StdEncoding.DecodeString(digest); err == nil && len(decoded) == 16 {
+			return DigestKindMD5Base64
+		}
+	}
+	return DigestKindUnknown
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// EqualContent reports whether two entries describe the same content.
+//
+// It compares Digest, Size, Ref, SymlinkTarget, ContentEncoding, Encryption
+// and Extra, and ignores fields that vary between fetches or machines, such as
+// DownloadURL and LocalPath. A nil Extra equals an empty one.
+func (e ManifestEntry) EqualContent(other ManifestEntry) bool {
+	if e.Digest != other.Digest || e.Size != other.Size {
+		return false
+	}
+	if (e.Ref == nil) != (other.Ref == nil) || e.Ref != nil && *e.Ref != *other.Ref {
+		return false
+	}
+	if (e.SymlinkTarget == nil) != (other.SymlinkTarget == nil) ||
+		e.SymlinkTarget != nil && *e.SymlinkTarget != *other.SymlinkTarget {
+		return false
+	}
+	if utils.ZeroIfNil(e.ContentEncoding) != utils.ZeroIfNil(other.ContentEncoding) {
+		return false
+	}
+	if (e.Encryption == nil) != (other.Encryption == nil) ||
+		e.Encryption != nil && *e.Encryption != *other.Encryption {
+		return false
+	}
+	if len(e.Extra) == 0 && len(other.Extra) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(e.Extra, other.Extra)
+}
@@ -0,0 +1,284 @@
+package artifacts
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wandb/wandb/nexus/pkg/utils"
+)
+
+func TestManifestEntryValidate(t *testing.T) {
+	ref := "s3://bucket/key"
+	localPath := "/tmp/file.txt"
+	downloadURL := "https://storage.example.com/abc"
+	tests := []struct {
+		name    string
+		entry   ManifestEntry
+		wantErr string
+	}{
+		{"valid stored entry", ManifestEntry{Digest: "abc", Size: 1}, ""},
+		{"valid reference without digest", ManifestEntry{Ref: &ref, Size: 1}, ""},
+		{"missing digest", ManifestEntry{Size: 1}, "missing digest"},
+		{"negative size", ManifestEntry{Digest: "abc", Size: -1}, "invalid size -1"},
+		{"valid reference with digest", ManifestEntry{Ref: &ref, Digest: "etag", Size: 1}, ""},
+		{"valid stored entry with download URL", ManifestEntry{Digest: "abc", DownloadURL: &downloadURL}, ""},
+		{
+			"reference and stored file",
+			ManifestEntry{Ref: &ref, Digest: "abc", DownloadURL: &downloadURL},
+			"both a reference and a stored file",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.entry.Validate()
+			if tt.wantErr == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+
+	unmaterializable := ManifestEntry{Digest: "abc", Size: 1}
+	assert.ErrorContains(t, unmaterializable.ValidateMaterializable(), "neither local path nor download URL")
+	materializable := ManifestEntry{Digest: "abc", Size: 1, LocalPath: &localPath}
+	assert.Nil(t, materializable.ValidateMaterializable())
+	reference := ManifestEntry{Ref: &ref}
+	assert.Nil(t, reference.ValidateMaterializable())
+}
+
+func TestManifestEntryRefScheme(t *testing.T) {
+	for ref, scheme := range map[string]string{
+		"s3://bucket/key.txt":             "s3",
+		"gs://bucket/dir/key.txt":         "gs",
+		"https://example.com/data.csv":    "https",
+		"file:///mnt/data/file.txt":       "file",
+		"wandb-artifact://abc123/file.md": "wandb-artifact",
+	} {
+		entry := ManifestEntry{Ref: &ref}
+		assert.True(t, entry.IsReference())
+		got, err := entry.RefScheme()
+		assert.Nil(t, err)
+		assert.Equal(t, scheme, got)
+	}
+
+	entry := ManifestEntry{Digest: "abc"}
+	assert.False(t, entry.IsReference())
+	_, err := entry.RefScheme()
+	assert.NotNil(t, err)
+
+	relative := "data/file.csv"
+	entry = ManifestEntry{Ref: &relative}
+	_, err = entry.RefScheme()
+	assert.ErrorContains(t, err, "has no scheme")
+}
+
+func TestManifestEntryExtraAccessors(t *testing.T) {
+	var entry ManifestEntry
+	assert.Nil(t, json.Unmarshal([]byte(`{
+		"digest": "abc",
+		"extra": {
+			"etag": "\"d41d8cd98f\"",
+			"versionID": 12345,
+			"ratio": 0.5,
+			"big": 1e30,
+			"flag": true
+		}
+	}`), &entry))
+
+	etag, ok := entry.ExtraString("etag")
+	assert.True(t, ok)
+	assert.Equal(t, `"d41d8cd98f"`, etag)
+	_, ok = entry.ExtraString("versionID")
+	assert.False(t, ok)
+	_, ok = entry.ExtraString("missing")
+	assert.False(t, ok)
+
+	versionID, ok := entry.ExtraInt64("versionID")
+	assert.True(t, ok)
+	assert.Equal(t, int64(12345), versionID)
+	for _, key := range []string{"ratio", "big", "flag", "etag", "missing"} {
+		_, ok = entry.ExtraInt64(key)
+		assert.False(t, ok, key)
+	}
+
+	entry.Extra["native"] = 7
+	native, ok := entry.ExtraInt64("native")
+	assert.True(t, ok)
+	assert.Equal(t, int64(7), native)
+	entry.Extra["number"] = json.Number("42")
+	number, ok := entry.ExtraInt64("number")
+	assert.True(t, ok)
+	assert.Equal(t, int64(42), number)
+}
+
+func TestManifestEntryVerifyChunks(t *testing.T) {
+	part1, part2 := []byte("hello, "), []byte("world")
+	digest1, _ := utils.ComputeB64MD5(part1)
+	digest2, _ := utils.ComputeB64MD5(part2)
+	content := bytes.NewReader(append(part1, part2...))
+
+	entry := ManifestEntry{
+		Digest: "etag-2",
+		Size:   int64(len(part1) + len(part2)),
+		Chunks: []ManifestChunk{
+			{Offset: 0, Size: int64(len(part1)), Digest: digest1},
+			{Offset: int64(len(part1)), Size: int64(len(part2)), Digest: digest2},
+		},
+	}
+	assert.Nil(t, entry.VerifyChunks(content))
+
+	entry.Chunks[1].Digest = digest1
+	assert.ErrorContains(t, entry.VerifyChunks(content), "chunk 1 digest mismatch")
+	assert.ErrorIs(t, entry.VerifyChunks(content), ErrDigestMismatch)
+
+	entry.Chunks[1].Size = 100
+	assert.ErrorContains(t, entry.VerifyChunks(content), "out of bounds")
+}
+
+func TestManifestEntryChunksOmittedWhenEmpty(t *testing.T) {
+	data, err := json.Marshal(ManifestEntry{Digest: "abc", Size: 1})
+	assert.Nil(t, err)
+	assert.NotContains(t, string(data), "chunks")
+}
+
+func TestManifestEntryResolveRef(t *testing.T) {
+	resolve := func(ref, base string) (string, error) {
+		entry := ManifestEntry{Ref: &ref}
+		return entry.ResolveRef(base)
+	}
+
+	resolved, err := resolve("data/file.csv", "s3://bucket/prefix/")
+	assert.Nil(t, err)
+	assert.Equal(t, "s3://bucket/prefix/data/file.csv", resolved)
+
+	resolved, err = resolve("../other/file.csv", "gs://bucket/prefix/sub/")
+	assert.Nil(t, err)
+	assert.Equal(t, "gs://bucket/prefix/other/file.csv", resolved)
+
+	resolved, err = resolve("s3://elsewhere/key.txt", "s3://bucket/prefix/")
+	assert.Nil(t, err)
+	assert.Equal(t, "s3://elsewhere/key.txt", resolved)
+
+	_, err = resolve("data/%zz", "s3://bucket/prefix/")
+	assert.ErrorContains(t, err, "invalid reference")
+	_, err = resolve("data/file.csv", "relative/base/")
+	assert.ErrorContains(t, err, "not absolute")
+
+	entry := ManifestEntry{Digest: "abc"}
+	_, err = entry.ResolveRef("s3://bucket/")
+	assert.ErrorContains(t, err, "not a reference")
+}
+
+func TestManifestEntryDigestKind(t *testing.T) {
+	for digest, kind := range map[string]DigestKind{
+		"1B2M2Y8AsgTpgAmY7PhCfg==":                                         DigestKindMD5Base64,
+		"d41d8cd98f00b204e9800998ecf8427e":                                 DigestKindMD5Hex,
+		`"d41d8cd98f00b204e9800998ecf8427e"`:                               DigestKindMD5Hex,
+		"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855": DigestKindSHA256Hex,
+		"9b2cf535f27731c974343645a3985328-5":                               DigestKindMultipartETag,
+		`"9b2cf535f27731c974343645a3985328-12"`:                            DigestKindMultipartETag,
+		"9b2cf535f27731c974343645a3985328-x":                               DigestKindUnknown,
+		"":                                                                 DigestKindUnknown,
+		"not-a-digest":                                                     DigestKindUnknown,
+		"1B2M2Y8AsgTpgAmY7PhCf!==":                                         DigestKindUnknown,
+	} {
+		entry := ManifestEntry{Digest: digest}
+		assert.Equal(t, kind, entry.DigestKind(), digest)
+	}
+}
+
+func TestManifestEntryEqualContent(t *testing.T) {
+	url1, url2 := "https://storage.example.com/1", "https://storage.example.com/2"
+	path1, path2 := "/a/file.txt", "/b/file.txt"
+	ref1, ref1Copy, ref2 := "s3://bucket/key", "s3://bucket/key", "s3://bucket/other"
+
+	base := ManifestEntry{Digest: "abc", Size: 3, DownloadURL: &url1, LocalPath: &path1}
+	volatile := ManifestEntry{Digest: "abc", Size: 3, DownloadURL: &url2, LocalPath: &path2}
+	assert.True(t, base.EqualContent(volatile))
+
+	withEmptyExtra := volatile
+	withEmptyExtra.Extra = map[string]interface{}{}
+	assert.True(t, base.EqualContent(withEmptyExtra))
+
+	otherDigest := base
+	otherDigest.Digest = "abd"
+	assert.False(t, base.EqualContent(otherDigest))
+
+	otherSize := base
+	otherSize.Size = 4
+	assert.False(t, base.EqualContent(otherSize))
+
+	withExtra := base
+	withExtra.Extra = map[string]interface{}{"etag": "x"}
+	assert.False(t, base.EqualContent(withExtra))
+
+	refA := ManifestEntry{Digest: "abc", Ref: &ref1}
+	refB := ManifestEntry{Digest: "abc", Ref: &ref1Copy}
+	refC := ManifestEntry{Digest: "abc", Ref: &ref2}
+	assert.True(t, refA.EqualContent(refB))
+	assert.False(t, refA.EqualContent(refC))
+	assert.False(t, refA.EqualContent(ManifestEntry{Digest: "abc"}))
+
+	target := "real.txt"
+	symlink := base
+	symlink.SymlinkTarget = &target
+	assert.False(t, base.EqualContent(symlink))
+}
+
+func TestManifestEntryContentEncoding(t *testing.T) {
+	var compressed bytes.Buffer
+	gzipWriter := gzip.NewWriter(&compressed)
+	_, err := gzipWriter.Write([]byte("contents"))
+	assert.Nil(t, err)
+	assert.Nil(t, gzipWriter.Close())
+
+	gzipEncoding := "gzip"
+	gzipped := ManifestEntry{Digest: "a", ContentEncoding: &gzipEncoding}
+	assert.True(t, gzipped.IsCompressed())
+	r, err := gzipped.DecodeContent(&compressed)
+	assert.Nil(t, err)
+	data, err := io.ReadAll(r)
+	assert.Nil(t, err)
+	assert.Equal(t, "contents", string(data))
+
+	identity := "identity"
+	for _, plain := range []ManifestEntry{{Digest: "a"}, {Digest: "a", ContentEncoding: &identity}} {
+		assert.False(t, plain.IsCompressed())
+		r, err := plain.DecodeContent(strings.NewReader("contents"))
+		assert.Nil(t, err)
+		data, err := io.ReadAll(r)
+		assert.Nil(t, err)
+		assert.Equal(t, "contents", string(data))
+	}
+	assert.False(t, gzipped.EqualContent(ManifestEntry{Digest: "a"}))
+
+	brotli := "br"
+	_, err = (&ManifestEntry{Digest: "a", ContentEncoding: &brotli}).DecodeContent(strings.NewReader(""))
+	assert.ErrorContains(t, err, `unsupported content encoding "br"`)
+}
+
+func TestManifestEntryContentType(t *testing.T) {
+	entry := ManifestEntry{Digest: "a"}
+	assert.Equal(t, "application/json", entry.ContentType("dir/config.json"))
+	assert.Equal(t, "image/png", entry.ContentType("images/cat.PNG"))
+	assert.Equal(t, "application/octet-stream", entry.ContentType("model.weights-unknown"))
+	assert.Equal(t, "application/octet-stream", entry.ContentType("Makefile"))
+
+	override := ManifestEntry{Digest: "a", Extra: map[string]interface{}{"contentType": "text/csv"}}
+	assert.Equal(t, "text/csv", override.ContentType("data.bin"))
+}
+
+func TestManifestEntryIsDir(t *testing.T) {
+	ref := "s3://bucket/dir/"
+	placeholder := ManifestEntry{Digest: emptyMD5Digest}
+	assert.True(t, placeholder.IsDir("dir/"))
+	assert.False(t, placeholder.IsDir("empty.txt"))
+	assert.False(t, (&ManifestEntry{Digest: "a", Size: 1}).IsDir("dir/"))
+	assert.False(t, (&ManifestEntry{Ref: &ref}).IsDir("dir/"))
+}
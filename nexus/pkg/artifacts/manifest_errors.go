@@ -0,0 +1,27 @@
+package artifacts
+
+import "errors"
+
+// Errors returned, wrapped with more detail, by manifest operations. Use
+// errors.Is to check for them.
+var (
+	// ErrPathNotFound means that a path is not in the manifest.
+	ErrPathNotFound = errors.New("path not contained in artifact")
+
+	// ErrManifestVersionUnsupported means that a manifest is newer than
+	// SupportedManifestVersion.
+	ErrManifestVersionUnsupported = errors.New("unsupported manifest version")
+
+	// ErrDigestMismatch means that some content does not have the digest
+	// recorded for it in the manifest.
+	ErrDigestMismatch = errors.New("digest mismatch")
+
+	// ErrNotModified is returned, along with the previously loaded manifest,
+	// when a loader with ConditionalGet set finds that a manifest has not
+	// changed.
+	ErrNotModified = errors.New("manifest not modified")
+
+	// ErrManifestConflict means that a conditional manifest write was
+	// rejected because the stored manifest changed since it was read.
+	ErrManifestConflict = errors.New("manifest was modified concurrently")
+)
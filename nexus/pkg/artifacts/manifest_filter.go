@@ -0,0 +1,92 @@
+package artifacts
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// filtered returns a manifest with m's metadata and the entries for which keep
+// returns true.
+func (m *Manifest) filtered(keep func(path string, entry ManifestEntry) bool) Manifest {
+	result := Manifest{
+		Version:             m.Version,
+		StoragePolicy:       m.StoragePolicy,
+		StoragePolicyConfig: m.StoragePolicyConfig,
+		Contents:            make(map[string]ManifestEntry),
+	}
+	for path, entry := range m.Contents {
+		if keep(path, entry) {
+			result.Contents[path] = entry
+		}
+	}
+	return result
+}
+
+// FilterByPattern returns a manifest containing only the entries whose paths
+// match pattern, using the syntax of path.Match.
+func (m *Manifest) FilterByPattern(pattern string) (Manifest, error) {
+	// Match only reports a malformed pattern if it gets far enough to notice,
+	// so check it up front.
+	if _, err := path.Match(pattern, ""); err != nil {
+		return Manifest{}, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	return m.filtered(func(p string, _ ManifestEntry) bool {
+		matched, _ := path.Match(pattern, p)
+		return matched
+	}), nil
+}
+
+// FilterByTag returns a manifest containing only the entries tagged with tag.
+func (m *Manifest) FilterByTag(tag string) Manifest {
+	return m.filtered(func(_ string, entry ManifestEntry) bool {
+		for _, t := range entry.Tags {
+			if t == tag {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// FilterBySize returns a manifest containing only the entries whose sizes are
+// at least minBytes and at most maxBytes. If maxBytes <= 0, there is no upper
+// bound.
+func (m *Manifest) FilterBySize(minBytes, maxBytes int64) Manifest {
+	return m.filtered(func(_ string, entry ManifestEntry) bool {
+		return entry.Size >= minBytes && (maxBytes <= 0 || entry.Size <= maxBytes)
+	})
+}
+
+// Subtree returns a manifest containing only the entries under the directory
+// prefix. A trailing slash on prefix is optional; an empty prefix selects the
+// whole manifest.
+func (m *Manifest) Subtree(prefix string) Manifest {
+	dir := subtreeDir(prefix)
+	return m.filtered(func(p string, _ ManifestEntry) bool {
+		return strings.HasPrefix(p, dir)
+	})
+}
+
+// SubtreeStripped is like Subtree, but removes prefix from the paths of the
+// returned entries, so that they are relative to the subtree's root.
+func (m *Manifest) SubtreeStripped(prefix string) Manifest {
+	dir := subtreeDir(prefix)
+	subtree := m.Subtree(prefix)
+	stripped := make(map[string]ManifestEntry, len(subtree.Contents))
+	for p, entry := range subtree.Contents {
+		stripped[strings.TrimPrefix(p, dir)] = entry
+	}
+	subtree.Contents = stripped
+	return subtree
+}
+
+// subtreeDir returns prefix with exactly one trailing slash, or the empty
+// string if prefix is empty.
+func subtreeDir(prefix string) string {
+	prefix = strings.TrimRight(prefix, "/")
+	if prefix == "" {
+		return ""
+	}
+	return prefix + "/"
+}
@@ -0,0 +1,110 @@
+package artifacts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newFilterTestManifest() Manifest {
+	return Manifest{
+		Version:             1,
+		StoragePolicy:       "wandb-storage-policy-v1",
+		StoragePolicyConfig: StoragePolicyConfig{StorageLayout: StorageLayoutV2},
+		Contents: map[string]ManifestEntry{
+			"config.json":      {Digest: "1", Size: 10},
+			"data/train.json":  {Digest: "2", Size: 200},
+			"data/train.csv":   {Digest: "3", Size: 3000},
+			"model/weights.pt": {Digest: "4", Size: 40000},
+		},
+	}
+}
+
+func TestManifestFilterByPattern(t *testing.T) {
+	manifest := newFilterTestManifest()
+
+	filtered, err := manifest.FilterByPattern("*.json")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"config.json"}, filtered.SortedPaths())
+	assert.Equal(t, manifest.Version, filtered.Version)
+	assert.Equal(t, manifest.StoragePolicy, filtered.StoragePolicy)
+	assert.Equal(t, manifest.StoragePolicyConfig, filtered.StoragePolicyConfig)
+
+	filtered, err = manifest.FilterByPattern("data/*")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"data/train.csv", "data/train.json"}, filtered.SortedPaths())
+
+	filtered, err = manifest.FilterByPattern("*.parquet")
+	assert.Nil(t, err)
+	assert.Empty(t, filtered.Contents)
+
+	_, err = manifest.FilterByPattern("[")
+	assert.ErrorContains(t, err, "invalid pattern")
+}
+
+func TestManifestSubtree(t *testing.T) {
+	manifest := newFilterTestManifest()
+	manifest.Contents["database.txt"] = ManifestEntry{Digest: "5", Size: 5}
+
+	for _, prefix := range []string{"data", "data/", "data//"} {
+		subtree := manifest.Subtree(prefix)
+		assert.Equal(t, []string{"data/train.csv", "data/train.json"}, subtree.SortedPaths(), prefix)
+		assert.Equal(t, manifest.StoragePolicy, subtree.StoragePolicy)
+
+		stripped := manifest.SubtreeStripped(prefix)
+		assert.Equal(t, []string{"train.csv", "train.json"}, stripped.SortedPaths(), prefix)
+		assert.Equal(t, "3", stripped.Contents["train.csv"].Digest)
+	}
+
+	full := manifest.Subtree("")
+	assert.Equal(t, manifest.Contents, full.Contents)
+	fullStripped := manifest.SubtreeStripped("")
+	assert.Equal(t, manifest.Contents, fullStripped.Contents)
+
+	assert.Empty(t, manifest.Subtree("missing").Contents)
+}
+
+func TestManifestFilterByTag(t *testing.T) {
+	manifest := newFilterTestManifest()
+	for path, tags := range map[string][]string{
+		"config.json":      {"config"},
+		"model/weights.pt": {"checkpoint", "large"},
+		"data/train.csv":   {"large"},
+	} {
+		entry := manifest.Contents[path]
+		entry.Tags = tags
+		manifest.Contents[path] = entry
+	}
+
+	configs := manifest.FilterByTag("config")
+	assert.Equal(t, []string{"config.json"}, configs.SortedPaths())
+	large := manifest.FilterByTag("large")
+	assert.Equal(t, []string{"data/train.csv", "model/weights.pt"}, large.SortedPaths())
+	checkpoints := manifest.FilterByTag("checkpoint")
+	assert.Equal(t, []string{"checkpoint", "large"}, checkpoints.Contents["model/weights.pt"].Tags)
+	assert.Equal(t, manifest.StoragePolicy, checkpoints.StoragePolicy)
+	assert.Empty(t, manifest.FilterByTag("missing").Contents)
+}
+
+func TestManifestFilterBySize(t *testing.T) {
+	manifest := Manifest{
+		Version: 1,
+		Contents: map[string]ManifestEntry{
+			"empty.txt":  {Digest: "a", Size: 0},
+			"small.txt":  {Digest: "b", Size: 10},
+			"medium.txt": {Digest: "c", Size: 100},
+			"large.bin":  {Digest: "d", Size: 1000},
+		},
+	}
+
+	inRange := manifest.FilterBySize(10, 100)
+	assert.Equal(t, []string{"medium.txt", "small.txt"}, inRange.SortedPaths())
+	assert.Equal(t, manifest.Version, inRange.Version)
+	exact := manifest.FilterBySize(100, 100)
+	assert.Equal(t, []string{"medium.txt"}, exact.SortedPaths())
+	unbounded := manifest.FilterBySize(100, 0)
+	assert.Equal(t, []string{"large.bin", "medium.txt"}, unbounded.SortedPaths())
+	all := manifest.FilterBySize(0, -1)
+	assert.Len(t, all.Contents, 4)
+	assert.Empty(t, manifest.FilterBySize(101, 999).Contents)
+}
@@ -0,0 +1,89 @@
+package artifacts
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// firstObjectExtraManifestVersion is the first manifest version whose
+// entries store Extra as a JSON object. Earlier manifests stored it as an
+// array of key/value pairs.
+const firstObjectExtraManifestVersion int32 = 1
+
+// unmarshalManifest decodes a JSON manifest, choosing how to decode entries'
+// Extra based on the manifest's version, and returns it in the current
+// in-memory form.
+func unmarshalManifest(data []byte) (Manifest, error) {
+	var header struct {
+		Version int32 `json:"version"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return Manifest{}, err
+	}
+
+	manifest := Manifest{}
+	if header.Version >= firstObjectExtraManifestVersion {
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return Manifest{}, err
+		}
+		return manifest, nil
+	}
+
+	var legacy legacyManifest
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return Manifest{}, err
+	}
+	manifest = legacy.Manifest
+	if legacy.Contents != nil {
+		manifest.Contents = make(map[string]ManifestEntry, len(legacy.Contents))
+	}
+	for path, entry := range legacy.Contents {
+		entry.ManifestEntry.Extra = entry.Extra
+		manifest.Contents[path] = entry.ManifestEntry
+	}
+	return manifest, nil
+}
+
+// legacyManifest is a manifest from before firstObjectExtraManifestVersion.
+// Its fields shadow those of the embedded Manifest when decoding.
+type legacyManifest struct {
+	Manifest
+	Contents map[string]legacyManifestEntry `json:"contents"`
+}
+
+type legacyManifestEntry struct {
+	ManifestEntry
+	Extra legacyExtra `json:"extra,omitempty"`
+}
+
+// legacyExtra decodes Extra stored as [{"key": ..., "value": ...}, ...].
+// The current object form is also accepted, since some older manifests were
+// rewritten in place without updating their version.
+type legacyExtra map[string]interface{}
+
+func (e *legacyExtra) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || data[0] != '[' {
+		var extra map[string]interface{}
+		if err := json.Unmarshal(data, &extra); err != nil {
+			return err
+		}
+		*e = extra
+		return nil
+	}
+
+	var items []struct {
+		Key   string      `json:"key"`
+		Value interface{} `json:"value"`
+	}
+	if err := json.Unmarshal(data, &items); err != nil {
+		return fmt.Errorf("invalid legacy extra: %w", err)
+	}
+	extra := make(map[string]interface{}, len(items))
+	for _, item := range items {
+		extra[item.Key] = item.Value
+	}
+	*e = extra
+	return nil
+}
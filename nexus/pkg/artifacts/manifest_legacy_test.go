@@ -0,0 +1,47 @@
+package artifacts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseManifestLegacyExtra(t *testing.T) {
+	current, err := parseManifest([]byte(`{
+		"version": 1,
+		"storagePolicy": "wandb-storage-policy-v1",
+		"storagePolicyConfig": {"storageLayout": "V1"},
+		"contents": {
+			"a.txt": {"digest": "a", "birthArtifactID": "id", "size": 1, "extra": {"etag": "x", "versionID": 7}},
+			"b.txt": {"digest": "b", "birthArtifactID": null, "size": 2}
+		}
+	}`))
+	assert.Nil(t, err)
+
+	legacy, err := parseManifest([]byte(`{
+		"storagePolicy": "wandb-storage-policy-v1",
+		"storagePolicyConfig": {"storageLayout": "V1"},
+		"contents": {
+			"a.txt": {"digest": "a", "birthArtifactID": "id", "size": 1, "extra": [
+				{"key": "etag", "value": "x"},
+				{"key": "versionID", "value": 7}
+			]},
+			"b.txt": {"digest": "b", "birthArtifactID": null, "size": 2}
+		}
+	}`))
+	assert.Nil(t, err)
+	assert.Equal(t, current.StoragePolicy, legacy.StoragePolicy)
+	assert.Equal(t, current.StoragePolicyConfig, legacy.StoragePolicyConfig)
+	assert.Equal(t, current.Contents, legacy.Contents)
+
+	rewritten, err := parseManifest([]byte(`{"contents": {"a.txt": {"digest": "a", "extra": {"etag": "x"}}}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"etag": "x"}, rewritten.Contents["a.txt"].Extra)
+
+	_, err = parseManifest([]byte(`{"contents": {"a.txt": {"digest": "a", "extra": [1]}}}`))
+	assert.ErrorContains(t, err, "invalid legacy extra")
+
+	// Current manifests do not accept the legacy form.
+	_, err = parseManifest([]byte(`{"version": 1, "contents": {"a.txt": {"digest": "a", "extra": []}}}`))
+	assert.ErrorContains(t, err, "error unmarshaling manifest")
+}
@@ -0,0 +1,893 @@
+package artifacts
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+
+	"github.com/wandb/wandb/nexus/pkg/utils"
+)
+
+// ManifestLoader fetches artifact manifests over HTTP.
+type ManifestLoader struct {
+	// Client is used for all HTTP requests. If nil, http.DefaultClient is
+	// used. Set it to configure TLS, proxies or timeouts.
+	Client *http.Client
+
+	// MaxRetries is the number of times a request is retried after a
+	// connection error or a 500, 502, 503 or 504 response.
+	MaxRetries int
+
+	// MaxRedirects is the number of redirects followed for each request. If
+	// zero, up to 10 are followed. Redirects from https to http are always
+	// refused, so that credentials are not sent in the clear.
+	MaxRedirects int
+
+	// BaseDelay is the wait before the first retry. The wait doubles with
+	// each subsequent retry, and some random jitter is added to it.
+	BaseDelay time.Duration
+
+	// AuthToken is sent as a bearer token in the Authorization header, if
+	// non-empty.
+	AuthToken string
+
+	// TokenFunc, if set, is called before each load to get the bearer token,
+	// and takes precedence over AuthToken. It allows tokens to be refreshed.
+	TokenFunc func() (string, error)
+
+	// Cache, if set, holds manifests loaded with LoadFromURLWithDigest so that
+	// later loads of the same digest skip the network.
+	Cache *ManifestCache
+
+	// ValidateSchema makes loads fail if the manifest is missing required
+	// fields. See Manifest.ValidateSchema.
+	ValidateSchema bool
+
+	// StrictContentType makes loads fail if the response has a Content-Type
+	// other than application/json, such as an HTML error page from a
+	// misconfigured proxy. A missing Content-Type is allowed.
+	StrictContentType bool
+
+	// ConditionalGet makes LoadFromURL and LoadFromURLCtx remember the ETag
+	// of each manifest they load and send it in an If-None-Match header when
+	// the same URL is loaded again. If the server responds that the manifest
+	// is unchanged, a copy of the previously loaded manifest is returned
+	// along with ErrNotModified.
+	ConditionalGet bool
+
+	// MaxManifestBytes, if positive, is the largest manifest body the loader
+	// will read, after decompression. Loads of larger manifests fail instead
+	// of exhausting memory. Use Client.Timeout to bound the time spent.
+	MaxManifestBytes int64
+
+	// ProgressFn, if set, is called as a manifest's body is read with the
+	// number of bytes read so far and the total from the Content-Length
+	// header or the size of a file:// manifest, or -1 if the total is unknown.
+	ProgressFn func(bytesRead, totalBytes int64)
+
+	// Metrics, if set, is told about each manifest load.
+	Metrics ManifestMetrics
+
+	etagsMu sync.Mutex
+	etags   map[string]etaggedManifest
+}
+
+// ManifestMetrics receives measurements of manifest loads, for example to
+// export them to Prometheus.
+type ManifestMetrics interface {
+	// ObserveLoad is called after each load by LoadFromURL, LoadFromURLCtx,
+	// LoadFromURLWithDigest or LoadFromURLStreaming with the time it took,
+	// the number of manifest bytes read, and the error it returned, if any.
+	// Loads served from the loader's Cache are not observed, and loads that
+	// find the manifest not modified are observed with a nil error.
+	//
+	// It may be called concurrently, such as by LoadSharded.
+	ObserveLoad(duration time.Duration, bytes int64, err error)
+}
+
+// etaggedManifest is a manifest loaded with ConditionalGet, and its ETag.
+type etaggedManifest struct {
+	etag     string
+	manifest Manifest
+}
+
+const (
+	defaultManifestMaxRetries = 3
+	defaultManifestBaseDelay  = 1 * time.Second
+	maxManifestRetryDelay     = 30 * time.Second
+	defaultManifestRedirects  = 10
+)
+
+// NewManifestLoader returns a ManifestLoader with the default retry settings.
+func NewManifestLoader() *ManifestLoader {
+	return &ManifestLoader{
+		Client:     http.DefaultClient,
+		MaxRetries: defaultManifestMaxRetries,
+		BaseDelay:  defaultManifestBaseDelay,
+	}
+}
+
+var defaultManifestLoader = NewManifestLoader()
+
+// manifestRetryPolicy retries connection errors and transient server errors,
+// but not client errors such as 403 or 404.
+func manifestRetryPolicy(ctx context.Context, resp *http.Response, err error) (bool, error) {
+	if errors.Is(err, errManifestRedirect) {
+		return false, err
+	}
+	if err != nil || ctx.Err() != nil {
+		return retryablehttp.DefaultRetryPolicy(ctx, resp, err)
+	}
+	switch resp.StatusCode {
+	case http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// manifestBackoff is an exponential backoff with up to 50% random jitter.
+func manifestBackoff(min, max time.Duration, attemptNum int, _ *http.Response) time.Duration {
+	if min <= 0 {
+		return 0
+	}
+	delay := min << attemptNum
+	if delay <= 0 || delay > max {
+		// delay <= 0 happens on overflow.
+		delay = max
+	}
+	return delay + time.Duration(rand.Int63n(int64(delay)/2+1))
+}
+
+func (l *ManifestLoader) newClient() *retryablehttp.Client {
+	client := retryablehttp.NewClient()
+	client.Logger = nil
+	client.RetryMax = l.MaxRetries
+	client.RetryWaitMin = l.BaseDelay
+	client.RetryWaitMax = maxManifestRetryDelay
+	client.CheckRetry = manifestRetryPolicy
+	client.Backoff = manifestBackoff
+	httpClient := http.DefaultClient
+	if l.Client != nil {
+		httpClient = l.Client
+	}
+	// Copy the client so that the redirect policy doesn't affect other users.
+	redirectingClient := *httpClient
+	redirectingClient.CheckRedirect = l.checkRedirect(httpClient.CheckRedirect)
+	client.HTTPClient = &redirectingClient
+	return client
+}
+
+// errManifestRedirect is wrapped by errors for redirects the loader refuses
+// to follow. Such errors are not retried.
+var errManifestRedirect = errors.New("manifest redirect refused")
+
+// checkRedirect returns an http.Client.CheckRedirect policy that enforces
+// MaxRedirects and refuses https to http downgrades before deferring to
+// next, if it is non-nil.
+func (l *ManifestLoader) checkRedirect(
+	next func(req *http.Request, via []*http.Request) error,
+) func(req *http.Request, via []*http.Request) error {
+	maxRedirects := l.MaxRedirects
+	if maxRedirects <= 0 {
+		maxRedirects = defaultManifestRedirects
+	}
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) > maxRedirects {
+			return fmt.Errorf("%w: stopped after %d redirects", errManifestRedirect, maxRedirects)
+		}
+		if previous := via[len(via)-1]; previous.URL.Scheme == "https" && req.URL.Scheme == "http" {
+			return fmt.Errorf(
+				"%w: redirect from https to http is not allowed: %s",
+				errManifestRedirect, req.URL.Redacted(),
+			)
+		}
+		if next != nil {
+			return next(req, via)
+		}
+		return nil
+	}
+}
+
+// authToken returns the bearer token to authenticate with, if any.
+func (l *ManifestLoader) authToken() (string, error) {
+	if l.TokenFunc != nil {
+		return l.TokenFunc()
+	}
+	return l.AuthToken, nil
+}
+
+// authorize sets the request's Authorization header if there is an auth
+// token.
+func (l *ManifestLoader) authorize(req *retryablehttp.Request) error {
+	token, err := l.authToken()
+	if err != nil {
+		return fmt.Errorf("error getting manifest auth token: %w", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return nil
+}
+
+// get issues a GET request for the manifest at url, retrying as configured,
+// and returns the response if it has a 200 status code.
+func (l *ManifestLoader) get(ctx context.Context, url string) (*http.Response, error) {
+	return l.getWithHeader(ctx, url, nil)
+}
+
+// getWithHeader is like get, but adds header to the request. If header has
+// an If-None-Match value and the server responds with 304 Not Modified,
+// ErrNotModified is returned. If header has a Range value, a 206 Partial
+// Content response is also accepted, and a 416 response results in
+// errRangeNotSatisfiable.
+func (l *ManifestLoader) getWithHeader(
+	ctx context.Context,
+	url string,
+	header http.Header,
+) (*http.Response, error) {
+	req, err := retryablehttp.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	// Setting this explicitly disables net/http's transparent decompression,
+	// so gzipped responses are decompressed below.
+	if req.Header.Get("Accept-Encoding") == "" {
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
+	if err := l.authorize(req); err != nil {
+		return nil, err
+	}
+	resp, err := l.newClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified && header.Get("If-None-Match") != "" {
+		resp.Body.Close()
+		return nil, ErrNotModified
+	}
+	isRange := header.Get("Range") != ""
+	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && isRange {
+		resp.Body.Close()
+		return nil, errRangeNotSatisfiable
+	}
+	if resp.StatusCode != http.StatusOK && !(resp.StatusCode == http.StatusPartialContent && isRange) {
+		resp.Body.Close()
+		return nil, fmt.Errorf("request to get manifest from url failed with status code: %d", resp.StatusCode)
+	}
+	if l.StrictContentType {
+		if err := checkManifestContentType(resp.Header.Get("Content-Type")); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+	}
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		gzipReader, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			resp.Body.Close()
+			return nil, fmt.Errorf("error decompressing manifest: %w", err)
+		}
+		resp.Body = &gzipReadCloser{Reader: gzipReader, body: resp.Body}
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = -1
+		resp.Uncompressed = true
+	}
+	resp.Body = l.wrapBody(resp.Body, resp.ContentLength)
+	return resp, nil
+}
+
+// wrapBody applies the loader's MaxManifestBytes and ProgressFn to a manifest
+// body of total bytes, or -1 if unknown.
+func (l *ManifestLoader) wrapBody(body io.ReadCloser, total int64) io.ReadCloser {
+	if l.MaxManifestBytes > 0 {
+		body = &maxBytesReadCloser{
+			Reader: io.LimitReader(body, l.MaxManifestBytes+1),
+			body:   body,
+			max:    l.MaxManifestBytes,
+		}
+	}
+	if l.ProgressFn != nil {
+		body = &progressReadCloser{
+			ReadCloser: body,
+			total:      total,
+			progressFn: l.ProgressFn,
+		}
+	}
+	return body
+}
+
+// maxBytesReadCloser fails reads once more than max bytes have been read
+// from a response body.
+type maxBytesReadCloser struct {
+	io.Reader
+	body io.ReadCloser
+	read int64
+	max  int64
+}
+
+func (m *maxBytesReadCloser) Read(b []byte) (int, error) {
+	n, err := m.Reader.Read(b)
+	m.read += int64(n)
+	if m.read > m.max {
+		return n, fmt.Errorf("manifest exceeds max size of %d bytes", m.max)
+	}
+	return n, err
+}
+
+func (m *maxBytesReadCloser) Close() error {
+	return m.body.Close()
+}
+
+// progressReadCloser reports the number of bytes read from a response body.
+type progressReadCloser struct {
+	io.ReadCloser
+	read       int64
+	total      int64
+	progressFn func(bytesRead, totalBytes int64)
+}
+
+func (p *progressReadCloser) Read(b []byte) (int, error) {
+	n, err := p.ReadCloser.Read(b)
+	if n > 0 {
+		p.read += int64(n)
+		p.progressFn(p.read, p.total)
+	}
+	return n, err
+}
+
+// checkManifestContentType returns an error unless contentType is empty or
+// application/json, ignoring parameters such as charset.
+func checkManifestContentType(contentType string) error {
+	if contentType == "" {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != "application/json" {
+		return fmt.Errorf(
+			"manifest response has content type %q, expected application/json",
+			contentType,
+		)
+	}
+	return nil
+}
+
+// gzipReadCloser decompresses a response body and closes it when done.
+type gzipReadCloser struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+func (g *gzipReadCloser) Close() error {
+	gzipErr := g.Reader.Close()
+	if err := g.body.Close(); err != nil {
+		return err
+	}
+	return gzipErr
+}
+
+// LoadFromURL downloads and parses the manifest at url.
+func (l *ManifestLoader) LoadFromURL(url string) (Manifest, error) {
+	return l.LoadFromURLCtx(context.Background(), url)
+}
+
+// LoadFromURLCtx is like LoadFromURL, but gives up when ctx is done.
+func (l *ManifestLoader) LoadFromURLCtx(ctx context.Context, url string) (Manifest, error) {
+	start := time.Now()
+	manifest, n, err := l.loadFromURL(ctx, url)
+	observed := err
+	if errors.Is(err, ErrNotModified) {
+		observed = nil
+	}
+	l.observeLoad(start, n, observed)
+	return manifest, err
+}
+
+// loadFromURL implements LoadFromURLCtx, additionally returning the number of
+// bytes read.
+func (l *ManifestLoader) loadFromURL(ctx context.Context, url string) (Manifest, int64, error) {
+	if _, isFile, _ := fileURLPath(url); l.ConditionalGet && !isFile {
+		return l.loadConditional(ctx, url)
+	}
+	body, err := l.download(ctx, url)
+	if err != nil {
+		return Manifest{}, int64(len(body)), err
+	}
+	manifest, err := l.parse(body)
+	return manifest, int64(len(body)), err
+}
+
+// observeLoad reports a load that began at start to the loader's Metrics.
+func (l *ManifestLoader) observeLoad(start time.Time, bytes int64, err error) {
+	if l.Metrics != nil {
+		l.Metrics.ObserveLoad(time.Since(start), bytes, err)
+	}
+}
+
+// loadConditional loads the manifest at url with a conditional GET, based on
+// the ETag recorded the last time url was loaded, and returns the number of
+// bytes read.
+func (l *ManifestLoader) loadConditional(ctx context.Context, url string) (Manifest, int64, error) {
+	l.etagsMu.Lock()
+	previous, ok := l.etags[url]
+	l.etagsMu.Unlock()
+	header := http.Header{}
+	if ok {
+		header.Set("If-None-Match", previous.etag)
+	}
+
+	resp, err := l.getWithHeader(ctx, url, header)
+	if errors.Is(err, ErrNotModified) {
+		return copyManifest(previous.manifest), 0, ErrNotModified
+	}
+	if err != nil {
+		return Manifest{}, 0, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Manifest{}, int64(len(body)), fmt.Errorf("error reading response body: %v", err)
+	}
+	manifest, err := l.parse(body)
+	if err != nil {
+		return Manifest{}, int64(len(body)), err
+	}
+
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		l.etagsMu.Lock()
+		if l.etags == nil {
+			l.etags = make(map[string]etaggedManifest)
+		}
+		l.etags[url] = etaggedManifest{etag: etag, manifest: copyManifest(manifest)}
+		l.etagsMu.Unlock()
+	}
+	return manifest, int64(len(body)), nil
+}
+
+// LoadFromURLWithDigest is like LoadFromURL, but first checks that the
+// downloaded bytes have the base64-encoded MD5 digest expectedDigest.
+//
+// If the loader has a Cache containing expectedDigest, the cached manifest is
+// returned without making a request. Callers get their own copy of the
+// cached Contents, so changing it does not affect later loads.
+func (l *ManifestLoader) LoadFromURLWithDigest(
+	ctx context.Context,
+	url string,
+	expectedDigest string,
+) (_ Manifest, err error) {
+	if l.Cache != nil {
+		if manifest, ok := l.Cache.Get(expectedDigest); ok {
+			return copyManifest(*manifest), nil
+		}
+	}
+
+	start := time.Now()
+	body, err := l.download(ctx, url)
+	defer func() { l.observeLoad(start, int64(len(body)), err) }()
+	if err != nil {
+		return Manifest{}, err
+	}
+	digest, err := utils.ComputeB64MD5(body)
+	if err != nil {
+		return Manifest{}, err
+	}
+	if digest != expectedDigest {
+		return Manifest{}, fmt.Errorf(
+			"manifest %w: expected %s, got %s (%d bytes)",
+			ErrDigestMismatch, expectedDigest, digest, len(body),
+		)
+	}
+	manifest, err := l.parse(body)
+	if err != nil {
+		return Manifest{}, err
+	}
+	if l.Cache != nil {
+		cached := copyManifest(manifest)
+		l.Cache.Put(digest, &cached)
+	}
+	return manifest, nil
+}
+
+// download returns the full body of the manifest at url. A file:// URL is
+// read from the local filesystem.
+func (l *ManifestLoader) download(ctx context.Context, url string) ([]byte, error) {
+	body, err := l.open(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body: %v", err)
+	}
+	return data, nil
+}
+
+// open returns the body of the manifest at url, with the loader's size limit
+// and progress reporting applied. A file:// URL is opened from the local
+// filesystem.
+func (l *ManifestLoader) open(ctx context.Context, url string) (io.ReadCloser, error) {
+	path, ok, err := fileURLPath(url)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		resp, err := l.get(ctx, url)
+		if err != nil {
+			return nil, err
+		}
+		return resp.Body, nil
+	}
+
+	// A file has no Content-Type, so StrictContentType checks the one
+	// implied by its extension, if any.
+	if l.StrictContentType {
+		if err := checkManifestContentType(mime.TypeByExtension(filepath.Ext(path))); err != nil {
+			return nil, err
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading manifest file: %w", err)
+	}
+	total := int64(-1)
+	if info, err := f.Stat(); err == nil && info.Mode().IsRegular() {
+		total = info.Size()
+	}
+	return l.wrapBody(f, total), nil
+}
+
+// LoadFromURLStreaming is like LoadFromURL, but decodes the response body
+// incrementally instead of reading it into memory first.
+//
+// If onEntry is non-nil, it is called for each entry in the manifest's
+// contents and the entries are not retained in the returned Manifest. This
+// lets callers process very large manifests in bounded memory.
+func (l *ManifestLoader) LoadFromURLStreaming(
+	url string,
+	onEntry func(path string, entry ManifestEntry) error,
+) (_ *Manifest, err error) {
+	start := time.Now()
+	counter := &countingWriter{w: io.Discard}
+	defer func() { l.observeLoad(start, counter.n, err) }()
+
+	body, err := l.open(context.Background(), url)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	manifest, err := decodeManifestStream(io.TeeReader(body, counter), onEntry)
+	if err != nil {
+		return nil, err
+	}
+	if err := l.validate(manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// maxConcurrentManifestShards bounds the number of manifest shards fetched at
+// once by LoadSharded.
+const maxConcurrentManifestShards = 8
+
+// LoadSharded fetches a manifest split across several URLs and merges the
+// shards with Manifest.Merge, failing if two shards disagree about a path.
+//
+// Shards are fetched concurrently, but merged in the order of urls so that
+// the result does not depend on which fetch finishes first. With
+// ConditionalGet, unchanged shards are taken from the previous load.
+func (l *ManifestLoader) LoadSharded(ctx context.Context, urls []string) (Manifest, error) {
+	if len(urls) == 0 {
+		return Manifest{}, fmt.Errorf("no manifest shards to load")
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	shards := make([]Manifest, len(urls))
+	errs := make([]error, len(urls))
+	semaphore := make(chan struct{}, maxConcurrentManifestShards)
+	wg := sync.WaitGroup{}
+	for i, url := range urls {
+		select {
+		case <-ctx.Done():
+			errs[i] = ctx.Err()
+			continue
+		case semaphore <- struct{}{}:
+		}
+		wg.Add(1)
+		go func(i int, url string) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			shards[i], errs[i] = l.LoadFromURLCtx(ctx, url)
+			if errors.Is(errs[i], ErrNotModified) {
+				// The previously loaded shard was returned.
+				errs[i] = nil
+			}
+			if errs[i] != nil {
+				cancel()
+			}
+		}(i, url)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		// Report the first error that isn't a cancellation we caused.
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return Manifest{}, fmt.Errorf("error loading manifest shard %s: %w", urls[i], err)
+		}
+	}
+	for i, err := range errs {
+		if err != nil {
+			return Manifest{}, fmt.Errorf("error loading manifest shard %s: %w", urls[i], err)
+		}
+	}
+
+	manifest := shards[0]
+	for i, shard := range shards[1:] {
+		if err := manifest.Merge(&shard); err != nil {
+			return Manifest{}, fmt.Errorf("error merging manifest shard %s: %w", urls[i+1], err)
+		}
+	}
+	return manifest, nil
+}
+
+// parse is like parseManifest, but also validates the manifest's schema if
+// the loader is configured to.
+func (l *ManifestLoader) parse(data []byte) (Manifest, error) {
+	manifest, err := parseManifest(data)
+	if err != nil {
+		return Manifest{}, err
+	}
+	if l.ValidateSchema {
+		if err := manifest.ValidateSchema(); err != nil {
+			return Manifest{}, err
+		}
+	}
+	return manifest, nil
+}
+
+// validate runs the checks that the loader applies to each manifest it loads.
+func (l *ManifestLoader) validate(manifest *Manifest) error {
+	if err := manifest.Validate(); err != nil {
+		return err
+	}
+	if l.ValidateSchema {
+		return manifest.ValidateSchema()
+	}
+	return nil
+}
+
+// parseManifest unmarshals and validates a JSON manifest. Manifests in older
+// formats are converted to the current one.
+func parseManifest(data []byte) (Manifest, error) {
+	manifest, err := unmarshalManifest(data)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("error unmarshaling manifest: %w", err)
+	}
+	if err := manifest.Validate(); err != nil {
+		return Manifest{}, err
+	}
+	return manifest, nil
+}
+
+// LoadManifest loads a manifest from location, which is an http(s) URL, a
+// file:// URL or a path on the local filesystem. Transports registered with
+// RegisterManifestTransport are consulted first.
+func LoadManifest(location string) (Manifest, error) {
+	if t, ok := manifestTransport(location); ok {
+		return loadManifestWithTransport(context.Background(), t, location)
+	}
+	if u, err := url.Parse(location); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		return loadManifestFromURL(location)
+	}
+	if path, ok, err := fileURLPath(location); err != nil {
+		return Manifest{}, err
+	} else if ok {
+		return loadManifestFromFile(path)
+	}
+	return loadManifestFromFile(location)
+}
+
+// fileURLPath returns the local filesystem path named by a file:// URL, and
+// false if rawURL is not one.
+//
+// Windows drive letters are accepted both as "file:///C:/dir" and as
+// "file://C:/dir". Any other host than "localhost" is an error, since the
+// file would not be on this machine.
+func fileURLPath(rawURL string) (string, bool, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "file" {
+		return "", false, nil
+	}
+	path := u.Path
+	switch {
+	case isWindowsDrive(u.Host):
+		path = u.Host + path
+	case u.Host != "" && !strings.EqualFold(u.Host, "localhost"):
+		return "", true, fmt.Errorf("file URL %s names another host %q", rawURL, u.Host)
+	case len(path) >= 3 && path[0] == '/' && isWindowsDrive(path[1:3]):
+		path = path[1:]
+	}
+	return filepath.FromSlash(path), true, nil
+}
+
+// isWindowsDrive reports whether s is a drive letter followed by a colon.
+func isWindowsDrive(s string) bool {
+	if len(s) != 2 || s[1] != ':' {
+		return false
+	}
+	c := s[0]
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// LoadManifestFromReader decodes and validates a JSON manifest read from r,
+// which must contain nothing but the manifest.
+func LoadManifestFromReader(r io.Reader) (Manifest, error) {
+	decoder := json.NewDecoder(r)
+	var data json.RawMessage
+	if err := decoder.Decode(&data); err != nil {
+		return Manifest{}, fmt.Errorf("error unmarshaling manifest: %w", err)
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return Manifest{}, fmt.Errorf("error unmarshaling manifest: unexpected data after manifest")
+	}
+	return parseManifest(data)
+}
+
+// loadManifestFromFile reads and parses a JSON manifest stored on disk.
+func loadManifestFromFile(path string) (Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("error reading manifest file: %w", err)
+	}
+	return parseManifest(data)
+}
+
+func loadManifestFromURL(url string) (Manifest, error) {
+	return loadManifestFromURLCtx(context.Background(), url)
+}
+
+func loadManifestFromURLCtx(ctx context.Context, url string) (Manifest, error) {
+	return defaultManifestLoader.LoadFromURLCtx(ctx, url)
+}
+
+// LoadShardedManifest calls LoadSharded on the default loader.
+func LoadShardedManifest(ctx context.Context, urls []string) (Manifest, error) {
+	return defaultManifestLoader.LoadSharded(ctx, urls)
+}
+
+func loadManifestFromURLWithDigest(url, expectedDigest string) (Manifest, error) {
+	return defaultManifestLoader.LoadFromURLWithDigest(context.Background(), url, expectedDigest)
+}
+
+// loadManifestFromURLStreaming calls LoadFromURLStreaming on the default
+// loader.
+func loadManifestFromURLStreaming(
+	url string,
+	onEntry func(path string, entry ManifestEntry) error,
+) (*Manifest, error) {
+	return defaultManifestLoader.LoadFromURLStreaming(url, onEntry)
+}
+
+// decodeManifestStream decodes a JSON manifest from r one entry at a time.
+//
+// See ManifestLoader.LoadFromURLStreaming for the meaning of onEntry.
+func decodeManifestStream(
+	r io.Reader,
+	onEntry func(path string, entry ManifestEntry) error,
+) (*Manifest, error) {
+	decoder := json.NewDecoder(r)
+	if err := expectJSONDelim(decoder, '{'); err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, fmt.Errorf("error decoding manifest: %w", err)
+		}
+		key, ok := token.(string)
+		if !ok {
+			return nil, fmt.Errorf("error decoding manifest: unexpected token %v", token)
+		}
+		switch key {
+		case "version":
+			err = decoder.Decode(&manifest.Version)
+		case "storagePolicy":
+			err = decoder.Decode(&manifest.StoragePolicy)
+		case "storagePolicyConfig":
+			err = decoder.Decode(&manifest.StoragePolicyConfig)
+		case "contents":
+			err = decodeManifestContents(decoder, manifest, onEntry)
+		default:
+			var ignored json.RawMessage
+			err = decoder.Decode(&ignored)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error decoding manifest field %q: %w", key, err)
+		}
+	}
+
+	if err := expectJSONDelim(decoder, '}'); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+func decodeManifestContents(
+	decoder *json.Decoder,
+	manifest *Manifest,
+	onEntry func(path string, entry ManifestEntry) error,
+) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token == nil {
+		// "contents": null
+		return nil
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return fmt.Errorf("expected object, got %v", token)
+	}
+
+	// Contents is left empty (but non-nil) when entries go to onEntry.
+	manifest.Contents = make(map[string]ManifestEntry)
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		path, ok := token.(string)
+		if !ok {
+			return fmt.Errorf("unexpected token %v", token)
+		}
+		// The version may come after the contents, so entries are always
+		// decoded in the legacy form, which also accepts the current one.
+		var legacy legacyManifestEntry
+		if err := decoder.Decode(&legacy); err != nil {
+			return fmt.Errorf("entry %q: %w", path, err)
+		}
+		entry := legacy.ManifestEntry
+		entry.Extra = legacy.Extra
+		if onEntry == nil {
+			manifest.Contents[path] = entry
+		} else if err := onEntry(path, entry); err != nil {
+			return err
+		}
+	}
+
+	return expectJSONDelim(decoder, '}')
+}
+
+func expectJSONDelim(decoder *json.Decoder, want json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return fmt.Errorf("error decoding manifest: %w", err)
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != want {
+		return fmt.Errorf("error decoding manifest: expected %v, got %v", want, token)
+	}
+	return nil
+}
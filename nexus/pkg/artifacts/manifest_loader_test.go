@@ -0,0 +1,754 @@
+package artifacts
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wandb/wandb/nexus/pkg/utils"
+)
+
+// writeSyntheticManifest writes a manifest with numEntries entries to w
+// without building it in memory first.
+func writeSyntheticManifest(w *bufio.Writer, numEntries int) {
+	fmt.Fprint(w, `{"version":1,"storagePolicy":"wandb-storage-policy-v1",`)
+	fmt.Fprint(w, `"storagePolicyConfig":{"storageLayout":"V2"},"contents":{`)
+	for i := 0; i < numEntries; i++ {
+		if i > 0 {
+			fmt.Fprint(w, ",")
+		}
+		fmt.Fprintf(w, `"dir/file-%d.txt":{"digest":"digest-%d","birthArtifactID":null,"size":%d}`, i, i, i)
+	}
+	fmt.Fprint(w, "}}")
+}
+
+func TestLoadManifestFromURLStreamingCallback(t *testing.T) {
+	const numEntries = 500_000
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bw := bufio.NewWriter(w)
+		writeSyntheticManifest(bw, numEntries)
+		_ = bw.Flush()
+	}))
+	defer server.Close()
+
+	count := 0
+	var totalSize int64
+	manifest, err := loadManifestFromURLStreaming(server.URL, func(path string, entry ManifestEntry) error {
+		count++
+		totalSize += entry.Size
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, numEntries, count)
+	assert.Equal(t, int64(numEntries)*(numEntries-1)/2, totalSize)
+	assert.Equal(t, int32(1), manifest.Version)
+	assert.Equal(t, "wandb-storage-policy-v1", manifest.StoragePolicy)
+	assert.Equal(t, "V2", manifest.StoragePolicyConfig.StorageLayout)
+	assert.Empty(t, manifest.Contents)
+}
+
+func TestLoadManifestFromURLStreamingCollect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bw := bufio.NewWriter(w)
+		writeSyntheticManifest(bw, 3)
+		_ = bw.Flush()
+	}))
+	defer server.Close()
+
+	manifest, err := loadManifestFromURLStreaming(server.URL, nil)
+	assert.Nil(t, err)
+	assert.Len(t, manifest.Contents, 3)
+	assert.Equal(t, "digest-2", manifest.Contents["dir/file-2.txt"].Digest)
+}
+
+func TestLoadManifestFromURLStreamingCallbackError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bw := bufio.NewWriter(w)
+		writeSyntheticManifest(bw, 10)
+		_ = bw.Flush()
+	}))
+	defer server.Close()
+
+	count := 0
+	_, err := loadManifestFromURLStreaming(server.URL, func(path string, entry ManifestEntry) error {
+		count++
+		if count == 5 {
+			return fmt.Errorf("stop")
+		}
+		return nil
+	})
+	assert.NotNil(t, err)
+	assert.Equal(t, 5, count)
+}
+
+func TestLoadManifestFromURLStreamingLegacyExtra(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The version comes after the contents, and the first entry has the
+		// legacy list form of extra.
+		fmt.Fprint(w, `{"storagePolicy":"wandb-storage-policy-v1","contents":{
+			"a.txt":{"digest":"a","size":1,"extra":[{"key":"etag","value":"x"},{"key":"versionID","value":7}]},
+			"b.txt":{"digest":"b","size":2,"extra":{"etag":"y"}},
+			"c.txt":{"digest":"c","size":3}
+		},"version":0}`)
+	}))
+	defer server.Close()
+
+	manifest, err := loadManifestFromURLStreaming(server.URL, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(0), manifest.Version)
+	assert.Equal(t, map[string]interface{}{"etag": "x", "versionID": float64(7)}, manifest.Contents["a.txt"].Extra)
+	assert.Equal(t, map[string]interface{}{"etag": "y"}, manifest.Contents["b.txt"].Extra)
+	assert.Nil(t, manifest.Contents["c.txt"].Extra)
+}
+
+func newTestManifestLoader() *ManifestLoader {
+	return &ManifestLoader{MaxRetries: 3, BaseDelay: time.Millisecond}
+}
+
+func TestManifestLoaderRetriesServerErrors(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"version":1,"contents":{"a.txt":{"digest":"x","size":1}}}`))
+	}))
+	defer server.Close()
+
+	manifest, err := newTestManifestLoader().LoadFromURL(server.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, "x", manifest.Contents["a.txt"].Digest)
+}
+
+func TestManifestLoaderGivesUpAfterMaxRetries(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	_, err := newTestManifestLoader().LoadFromURL(server.URL)
+	assert.NotNil(t, err)
+	assert.Equal(t, 4, attempts)
+}
+
+func TestManifestLoaderDoesNotRetryClientErrors(t *testing.T) {
+	for _, status := range []int{http.StatusNotFound, http.StatusForbidden} {
+		attempts := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			w.WriteHeader(status)
+		}))
+
+		_, err := newTestManifestLoader().LoadFromURL(server.URL)
+		assert.NotNil(t, err)
+		assert.Equal(t, 1, attempts)
+		server.Close()
+	}
+}
+
+func TestLoadManifestFromURLMalformedJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"version":1,"contents":{`))
+	}))
+	defer server.Close()
+
+	manifest, err := newTestManifestLoader().LoadFromURL(server.URL)
+	assert.ErrorContains(t, err, "error unmarshaling manifest")
+	assert.Equal(t, Manifest{}, manifest)
+}
+
+func TestLoadManifestFromURLRejectsNewerVersion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"version":2,"contents":{}}`))
+	}))
+	defer server.Close()
+
+	_, err := newTestManifestLoader().LoadFromURL(server.URL)
+	assert.ErrorContains(t, err, "unsupported manifest version")
+}
+
+func TestLoadManifestFromFileAndURL(t *testing.T) {
+	const body = `{"version":1,"contents":{"a.txt":{"digest":"x","size":1}}}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	assert.Nil(t, os.WriteFile(path, []byte(body), 0600))
+
+	fileURL := (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+	for _, location := range []string{server.URL, path, fileURL} {
+		manifest, err := LoadManifest(location)
+		assert.Nil(t, err)
+		assert.Equal(t, "x", manifest.Contents["a.txt"].Digest)
+	}
+
+	manifest, err := newTestManifestLoader().LoadFromURL(fileURL)
+	assert.Nil(t, err)
+	assert.Equal(t, "x", manifest.Contents["a.txt"].Digest)
+
+	_, err = LoadManifest(filepath.Join(t.TempDir(), "missing.json"))
+	assert.ErrorContains(t, err, "error reading manifest file")
+}
+
+func TestManifestLoaderFileURLOptions(t *testing.T) {
+	const body = `{"version":1,"contents":{"a.txt":{"digest":"x","size":1}}}`
+	dir := t.TempDir()
+	path := filepath.Join(dir, "manifest.json")
+	assert.Nil(t, os.WriteFile(path, []byte(body), 0600))
+	fileURL := (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+
+	loader := newTestManifestLoader()
+	var lastRead, lastTotal int64
+	loader.ProgressFn = func(bytesRead, totalBytes int64) {
+		lastRead, lastTotal = bytesRead, totalBytes
+	}
+	manifest, err := loader.LoadFromURL(fileURL)
+	assert.Nil(t, err)
+	assert.Equal(t, "x", manifest.Contents["a.txt"].Digest)
+	assert.Equal(t, int64(len(body)), lastRead)
+	assert.Equal(t, int64(len(body)), lastTotal)
+
+	streamed, err := loader.LoadFromURLStreaming(fileURL, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "x", streamed.Contents["a.txt"].Digest)
+
+	loader.MaxManifestBytes = 16
+	_, err = loader.LoadFromURL(fileURL)
+	assert.ErrorContains(t, err, "exceeds max size")
+	_, err = loader.LoadFromURLStreaming(fileURL, nil)
+	assert.ErrorContains(t, err, "exceeds max size")
+
+	htmlPath := filepath.Join(dir, "manifest.html")
+	assert.Nil(t, os.WriteFile(htmlPath, []byte(body), 0600))
+	htmlURL := (&url.URL{Scheme: "file", Path: filepath.ToSlash(htmlPath)}).String()
+	strict := newTestManifestLoader()
+	strict.StrictContentType = true
+	_, err = strict.LoadFromURL(htmlURL)
+	assert.ErrorContains(t, err, "content type")
+	_, err = strict.LoadFromURL(fileURL)
+	assert.Nil(t, err)
+}
+
+func TestFileURLPath(t *testing.T) {
+	for rawURL, expected := range map[string]string{
+		"file:///abs/path/manifest.json":     "/abs/path/manifest.json",
+		"file:///dir%20with%20spaces/m.json": "/dir with spaces/m.json",
+		"file:///C:/Users/me/manifest.json":  "C:/Users/me/manifest.json",
+		"file://c:/Users/me/manifest.json":   "c:/Users/me/manifest.json",
+		"file://localhost/abs/m.json":        "/abs/m.json",
+		"file://LOCALHOST/C:/m.json":         "C:/m.json",
+	} {
+		path, ok, err := fileURLPath(rawURL)
+		assert.Nil(t, err, rawURL)
+		assert.True(t, ok, rawURL)
+		assert.Equal(t, filepath.FromSlash(expected), path, rawURL)
+	}
+
+	for _, rawURL := range []string{"https://example.com/m.json", "/abs/path", "relative/path"} {
+		_, ok, err := fileURLPath(rawURL)
+		assert.Nil(t, err, rawURL)
+		assert.False(t, ok, rawURL)
+	}
+
+	// Files on other hosts are not read from this one.
+	_, _, err := fileURLPath("file://otherhost/etc/m.json")
+	assert.ErrorContains(t, err, `names another host "otherhost"`)
+	_, err = LoadManifest("file://otherhost/etc/m.json")
+	assert.ErrorContains(t, err, "another host")
+	_, err = newTestManifestLoader().LoadFromURL("file://otherhost/etc/m.json")
+	assert.ErrorContains(t, err, "another host")
+}
+
+func TestLoadManifestFromURLCtxDeadline(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := loadManifestFromURLCtx(ctx, server.URL)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestLoadManifestFromURLGzip(t *testing.T) {
+	const body = `{"version":1,"contents":{"a.txt":{"digest":"x","size":1}}}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/plain" {
+			_, _ = w.Write([]byte(body))
+			return
+		}
+		assert.Equal(t, "gzip", r.Header.Get("Accept-Encoding"))
+		w.Header().Set("Content-Encoding", "gzip")
+		gw := gzip.NewWriter(w)
+		_, _ = gw.Write([]byte(body))
+		_ = gw.Close()
+	}))
+	defer server.Close()
+
+	for _, path := range []string{"/plain", "/gzip"} {
+		manifest, err := newTestManifestLoader().LoadFromURL(server.URL + path)
+		assert.Nil(t, err)
+		assert.Equal(t, "x", manifest.Contents["a.txt"].Digest)
+	}
+}
+
+func TestLoadManifestFromURLWithDigest(t *testing.T) {
+	const body = `{"version":1,"contents":{"a.txt":{"digest":"x","size":1}}}`
+	digest, _ := utils.ComputeB64MD5([]byte(body))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/truncated" {
+			// Promise the whole body but send only half of it, so the
+			// connection is closed mid-transfer.
+			w.Header().Set("Content-Length", fmt.Sprint(len(body)))
+			_, _ = w.Write([]byte(body[:len(body)/2]))
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	manifest, err := loadManifestFromURLWithDigest(server.URL, digest)
+	assert.Nil(t, err)
+	assert.Equal(t, "x", manifest.Contents["a.txt"].Digest)
+
+	_, err = loadManifestFromURLWithDigest(server.URL, "bm90IHRoZSBkaWdlc3Q=")
+	assert.ErrorContains(t, err, "manifest digest mismatch")
+	assert.ErrorIs(t, err, ErrDigestMismatch)
+
+	_, err = loadManifestFromURLWithDigest(server.URL+"/truncated", digest)
+	assert.ErrorContains(t, err, "unexpected EOF")
+}
+
+func TestManifestLoaderValidateSchema(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"version":1,"contents":null}`))
+	}))
+	defer server.Close()
+
+	loader := newTestManifestLoader()
+	_, err := loader.LoadFromURL(server.URL)
+	assert.Nil(t, err)
+
+	loader.ValidateSchema = true
+	_, err = loader.LoadFromURL(server.URL)
+	assert.ErrorContains(t, err, `"storagePolicy"`)
+	assert.ErrorContains(t, err, `"contents"`)
+	_, err = loader.LoadFromURLStreaming(server.URL, nil)
+	assert.ErrorContains(t, err, `"contents"`)
+}
+
+// recordingTransport records the URLs of the requests it forwards.
+type recordingTransport struct {
+	urls []string
+}
+
+func (rt *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.urls = append(rt.urls, req.URL.String())
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestManifestLoaderUsesClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"version":1,"contents":{}}`))
+	}))
+	defer server.Close()
+
+	transport := &recordingTransport{}
+	loader := newTestManifestLoader()
+	loader.Client = &http.Client{Transport: transport}
+	_, err := loader.LoadFromURL(server.URL + "/manifest.json")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{server.URL + "/manifest.json"}, transport.urls)
+}
+
+func TestManifestLoaderAuthToken(t *testing.T) {
+	var authHeaders []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeaders = append(authHeaders, r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte(`{"version":1,"contents":{}}`))
+	}))
+	defer server.Close()
+
+	loader := newTestManifestLoader()
+	_, err := loader.LoadFromURL(server.URL)
+	assert.Nil(t, err)
+
+	loader.AuthToken = "static-token"
+	_, err = loader.LoadFromURL(server.URL)
+	assert.Nil(t, err)
+
+	loader.TokenFunc = func() (string, error) { return "refreshed-token", nil }
+	_, err = loader.LoadFromURL(server.URL)
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"", "Bearer static-token", "Bearer refreshed-token"}, authHeaders)
+
+	loader.TokenFunc = func() (string, error) { return "", errors.New("expired") }
+	_, err = loader.LoadFromURL(server.URL)
+	assert.ErrorContains(t, err, "expired")
+	assert.Len(t, authHeaders, 3)
+}
+
+func TestLoadShardedManifest(t *testing.T) {
+	shards := map[string]string{
+		"/1":        `{"version":1,"storagePolicy":"p","contents":{"a.txt":{"digest":"a","size":1}}}`,
+		"/2":        `{"version":1,"storagePolicy":"p","contents":{"b.txt":{"digest":"b","size":2}}}`,
+		"/overlap":  `{"version":1,"storagePolicy":"p","contents":{"a.txt":{"digest":"a","size":1}}}`,
+		"/conflict": `{"version":1,"storagePolicy":"p","contents":{"a.txt":{"digest":"A","size":1}}}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := shards[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	loader := newTestManifestLoader()
+	manifest, err := loader.LoadSharded(context.Background(), []string{server.URL + "/1", server.URL + "/2"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a.txt", "b.txt"}, manifest.SortedPaths())
+
+	manifest, err = loader.LoadSharded(context.Background(), []string{server.URL + "/1", server.URL + "/overlap"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a.txt"}, manifest.SortedPaths())
+
+	_, err = loader.LoadSharded(context.Background(), []string{server.URL + "/1", server.URL + "/conflict"})
+	assert.ErrorContains(t, err, "conflicting digests")
+
+	_, err = loader.LoadSharded(context.Background(), []string{server.URL + "/1", server.URL + "/missing"})
+	assert.ErrorContains(t, err, "/missing")
+	assert.ErrorContains(t, err, "404")
+}
+
+func TestLoadShardedManifestConditionalGet(t *testing.T) {
+	shards := map[string]string{
+		"/1": `{"version":1,"storagePolicy":"p","contents":{"a.txt":{"digest":"a","size":1}}}`,
+		"/2": `{"version":1,"storagePolicy":"p","contents":{"b.txt":{"digest":"b","size":2}}}`,
+	}
+	shard2Etag := `"/2"`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		etag := `"` + r.URL.Path + `"`
+		if r.URL.Path == "/2" {
+			etag = shard2Etag
+		}
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		_, _ = w.Write([]byte(shards[r.URL.Path]))
+	}))
+	defer server.Close()
+
+	loader := newTestManifestLoader()
+	loader.ConditionalGet = true
+	urls := []string{server.URL + "/1", server.URL + "/2"}
+	for i := 0; i < 2; i++ {
+		manifest, err := loader.LoadSharded(context.Background(), urls)
+		assert.Nil(t, err, i)
+		assert.Equal(t, []string{"a.txt", "b.txt"}, manifest.SortedPaths(), i)
+	}
+
+	// Merging into the unchanged first shard must not leak the second
+	// shard's old entries into later loads.
+	shards["/2"] = `{"version":1,"storagePolicy":"p","contents":{"c.txt":{"digest":"c","size":3}}}`
+	shard2Etag = `"/2-v2"`
+	manifest, err := loader.LoadSharded(context.Background(), urls)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a.txt", "c.txt"}, manifest.SortedPaths())
+	first, err := loader.LoadFromURL(urls[0])
+	assert.ErrorIs(t, err, ErrNotModified)
+	assert.Equal(t, []string{"a.txt"}, first.SortedPaths())
+}
+
+func TestManifestLoaderProgressFn(t *testing.T) {
+	const body = `{"version":1,"contents":{"a.txt":{"digest":"x","size":1}}}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/sized" {
+			w.Header().Set("Content-Length", fmt.Sprint(len(body)))
+		}
+		// Write in small flushed pieces so the body arrives in several reads.
+		for i := 0; i < len(body); i += 10 {
+			_, _ = w.Write([]byte(body[i:min(i+10, len(body))]))
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer server.Close()
+
+	for path, expectedTotal := range map[string]int64{"/sized": int64(len(body)), "/chunked": -1} {
+		var reads []int64
+		loader := newTestManifestLoader()
+		loader.ProgressFn = func(bytesRead, totalBytes int64) {
+			assert.Equal(t, expectedTotal, totalBytes)
+			reads = append(reads, bytesRead)
+		}
+
+		manifest, err := loader.LoadFromURL(server.URL + path)
+		assert.Nil(t, err)
+		assert.Equal(t, "x", manifest.Contents["a.txt"].Digest)
+		assert.NotEmpty(t, reads)
+		for i := 1; i < len(reads); i++ {
+			assert.Greater(t, reads[i], reads[i-1])
+		}
+		assert.Equal(t, int64(len(body)), reads[len(reads)-1])
+	}
+}
+
+func TestManifestLoaderStrictContentType(t *testing.T) {
+	const body = `{"version":1,"contents":{"a.txt":{"digest":"x","size":1}}}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/html":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = w.Write([]byte("<html>Bad gateway</html>"))
+		case "/json":
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_, _ = w.Write([]byte(body))
+		}
+	}))
+	defer server.Close()
+
+	loader := newTestManifestLoader()
+	_, err := loader.LoadFromURL(server.URL + "/html")
+	assert.ErrorContains(t, err, "error unmarshaling manifest")
+
+	loader.StrictContentType = true
+	_, err = loader.LoadFromURL(server.URL + "/html")
+	assert.ErrorContains(t, err, `content type "text/html; charset=utf-8"`)
+
+	manifest, err := loader.LoadFromURL(server.URL + "/json")
+	assert.Nil(t, err)
+	assert.Equal(t, "x", manifest.Contents["a.txt"].Digest)
+}
+
+func TestManifestLoaderConditionalGet(t *testing.T) {
+	const body = `{"version":1,"contents":{"a.txt":{"digest":"x","size":1}}}`
+	const etag = `"v1"`
+	requests, notModified := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	loader := newTestManifestLoader()
+	loader.ConditionalGet = true
+	first, err := loader.LoadFromURL(server.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, "x", first.Contents["a.txt"].Digest)
+
+	second, err := loader.LoadFromURL(server.URL)
+	assert.ErrorIs(t, err, ErrNotModified)
+	assert.Equal(t, first, second)
+	assert.Equal(t, 2, requests)
+	assert.Equal(t, 1, notModified)
+
+	// Without ConditionalGet, no ETag is sent and the manifest is refetched.
+	plain := newTestManifestLoader()
+	for i := 0; i < 2; i++ {
+		_, err := plain.LoadFromURL(server.URL)
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, 1, notModified)
+}
+
+func TestLoadManifestFromReader(t *testing.T) {
+	var buf bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buf)
+	_, err := gzipWriter.Write([]byte(`{"version":1,"contents":{"a.txt":{"digest":"x","size":1}}}` + "\n"))
+	assert.Nil(t, err)
+	assert.Nil(t, gzipWriter.Close())
+	gzipReader, err := gzip.NewReader(&buf)
+	assert.Nil(t, err)
+
+	manifest, err := LoadManifestFromReader(gzipReader)
+	assert.Nil(t, err)
+	assert.Equal(t, "x", manifest.Contents["a.txt"].Digest)
+
+	for body, expected := range map[string]string{
+		`{"version":1,"contents":{`:    "error unmarshaling manifest",
+		`{"version":1,"contents":{}}x`: "unexpected data after manifest",
+		`{"version":2,"contents":{}}`:  "unsupported manifest version",
+		``:                             "error unmarshaling manifest",
+	} {
+		_, err := LoadManifestFromReader(strings.NewReader(body))
+		assert.ErrorContains(t, err, expected, body)
+	}
+}
+
+func TestManifestLoaderMaxManifestBytes(t *testing.T) {
+	const body = `{"version":1,"contents":{"a.txt":{"digest":"x","size":1}}}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/huge" {
+			for i := 0; i < 1000; i++ {
+				_, _ = w.Write([]byte(strings.Repeat(" ", 1024)))
+			}
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	loader := newTestManifestLoader()
+	loader.MaxManifestBytes = int64(len(body))
+	manifest, err := loader.LoadFromURL(server.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, "x", manifest.Contents["a.txt"].Digest)
+
+	_, err = loader.LoadFromURL(server.URL + "/huge")
+	assert.ErrorContains(t, err, fmt.Sprintf("manifest exceeds max size of %d bytes", len(body)))
+	_, err = loader.LoadFromURLStreaming(server.URL+"/huge", nil)
+	assert.ErrorContains(t, err, "manifest exceeds max size")
+}
+
+func TestManifestLoaderMaxRedirects(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		// /redirect/3 redirects to /redirect/2, and so on down to /redirect/0.
+		var remaining int
+		if _, err := fmt.Sscanf(r.URL.Path, "/redirect/%d", &remaining); err == nil && remaining > 0 {
+			http.Redirect(w, r, fmt.Sprintf("/redirect/%d", remaining-1), http.StatusFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"version":1,"contents":{"a.txt":{"digest":"x","size":1}}}`))
+	}))
+	defer server.Close()
+
+	loader := newTestManifestLoader()
+	loader.MaxRedirects = 3
+	manifest, err := loader.LoadFromURL(server.URL + "/redirect/3")
+	assert.Nil(t, err)
+	assert.Equal(t, "x", manifest.Contents["a.txt"].Digest)
+
+	attempts = 0
+	_, err = loader.LoadFromURL(server.URL + "/redirect/4")
+	assert.ErrorContains(t, err, "stopped after 3 redirects")
+	assert.Equal(t, 4, attempts, "redirect errors should not be retried")
+}
+
+func TestManifestLoaderRefusesRedirectDowngrade(t *testing.T) {
+	plainServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"version":1,"contents":{}}`))
+	}))
+	defer plainServer.Close()
+	tlsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, plainServer.URL+"/manifest.json", http.StatusFound)
+	}))
+	defer tlsServer.Close()
+
+	loader := newTestManifestLoader()
+	loader.Client = tlsServer.Client()
+	_, err := loader.LoadFromURL(tlsServer.URL)
+	assert.ErrorContains(t, err, "redirect from https to http is not allowed")
+	assert.Nil(t, tlsServer.Client().CheckRedirect, "the caller's client should not be modified")
+}
+
+// recordingMetrics is a ManifestMetrics that records each observation.
+type recordingMetrics struct {
+	mu           sync.Mutex
+	observations []loadObservation
+}
+
+type loadObservation struct {
+	duration time.Duration
+	bytes    int64
+	err      error
+}
+
+func (r *recordingMetrics) ObserveLoad(duration time.Duration, bytes int64, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.observations = append(r.observations, loadObservation{duration, bytes, err})
+}
+
+func TestManifestLoaderMetrics(t *testing.T) {
+	const body = `{"version":1,"contents":{"a.txt":{"digest":"x","size":1}}}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.URL.Path == "/etag" {
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"v1"`)
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	metrics := &recordingMetrics{}
+	loader := newTestManifestLoader()
+	loader.Metrics = metrics
+
+	_, err := loader.LoadFromURL(server.URL)
+	assert.Nil(t, err)
+	_, err = loader.LoadFromURLStreaming(server.URL, nil)
+	assert.Nil(t, err)
+	digest, _ := utils.ComputeB64MD5([]byte(body))
+	_, err = loader.LoadFromURLWithDigest(context.Background(), server.URL, digest)
+	assert.Nil(t, err)
+	_, err = loader.LoadFromURLWithDigest(context.Background(), server.URL, "wrong")
+	assert.ErrorIs(t, err, ErrDigestMismatch)
+	_, err = loader.LoadFromURL(server.URL + "/missing")
+	assert.NotNil(t, err)
+
+	// A manifest that hasn't changed is not a failed load.
+	loader.ConditionalGet = true
+	_, err = loader.LoadFromURL(server.URL + "/etag")
+	assert.Nil(t, err)
+	_, err = loader.LoadFromURL(server.URL + "/etag")
+	assert.ErrorIs(t, err, ErrNotModified)
+
+	assert.Len(t, metrics.observations, 7)
+	for i, observation := range metrics.observations[:3] {
+		assert.Nil(t, observation.err, i)
+		assert.Equal(t, int64(len(body)), observation.bytes, i)
+		assert.Greater(t, observation.duration, time.Duration(0), i)
+	}
+	mismatch := metrics.observations[3]
+	assert.ErrorIs(t, mismatch.err, ErrDigestMismatch)
+	assert.Equal(t, int64(len(body)), mismatch.bytes)
+	missing := metrics.observations[4]
+	assert.ErrorContains(t, missing.err, "status code: 404")
+	assert.Equal(t, int64(0), missing.bytes)
+	notModified := metrics.observations[6]
+	assert.Nil(t, notModified.err)
+	assert.Equal(t, int64(0), notModified.bytes)
+}
@@ -0,0 +1,313 @@
+package artifacts
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/wandb/wandb/nexus/pkg/utils"
+)
+
+// OpenEntry opens the local copy of the entry at path for reading.
+//
+// It is an error if the entry has no LocalPath.
+func (m *Manifest) OpenEntry(path string) (io.ReadCloser, error) {
+	entry, err := m.GetManifestEntryFromArtifactFilePath(path)
+	if err != nil {
+		return nil, err
+	}
+	if entry.LocalPath == nil {
+		return nil, fmt.Errorf("manifest entry %q has no local path", path)
+	}
+	f, err := os.Open(*entry.LocalPath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening manifest entry %q: %w", path, err)
+	}
+	return f, nil
+}
+
+// EntryFromLocalFile returns an entry for the regular file at path, with its
+// size, base64-encoded MD5 digest and LocalPath set.
+func EntryFromLocalFile(path string) (ManifestEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return ManifestEntry{}, fmt.Errorf("error opening %s: %w", path, err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return ManifestEntry{}, fmt.Errorf("error reading %s: %w", path, err)
+	}
+	if !info.Mode().IsRegular() {
+		return ManifestEntry{}, fmt.Errorf("%s is not a regular file", path)
+	}
+
+	hasher := md5.New()
+	// The size is taken from the bytes hashed, so that it matches the digest
+	// even if the file changes after the Stat above.
+	size, err := io.Copy(hasher, f)
+	if err != nil {
+		return ManifestEntry{}, fmt.Errorf("error reading %s: %w", path, err)
+	}
+	return ManifestEntry{
+		Digest:    base64.StdEncoding.EncodeToString(hasher.Sum(nil)),
+		Size:      size,
+		LocalPath: &path,
+	}, nil
+}
+
+// VerifyLocalFile checks that the file at the entry's LocalPath has the
+// entry's digest. Reference entries are not verified.
+func (e *ManifestEntry) VerifyLocalFile() error {
+	if e.Ref != nil {
+		return nil
+	}
+	if e.LocalPath == nil {
+		return fmt.Errorf("manifest entry has no local path")
+	}
+	digest, err := utils.ComputeFileB64MD5(*e.LocalPath)
+	if err != nil {
+		return fmt.Errorf("error computing digest of %s: %w", *e.LocalPath, err)
+	}
+	if digest != e.Digest {
+		return fmt.Errorf(
+			"%w for %s: expected %s, got %s",
+			ErrDigestMismatch, *e.LocalPath, e.Digest, digest,
+		)
+	}
+	return nil
+}
+
+// CreateSymlink recreates a symlink entry as a symbolic link at path, which
+// is relative to root, creating its parent directories as needed. An existing
+// file at path is left alone, unless it is a link that leads outside root.
+//
+// SymlinkTarget comes from the manifest, so it is not trusted: absolute
+// targets, targets that resolve outside root, and links whose parent
+// directories are themselves symlinks are rejected, so that later writes
+// through the link can't escape root. Once created, the link is resolved
+// through any links already under root, and removed if it leads outside.
+//
+// A link created later can change where an earlier one leads, so callers
+// creating several links should check each with confineSymlink once they
+// are all in place.
+func (e *ManifestEntry) CreateSymlink(root, path string) error {
+	if e.SymlinkTarget == nil {
+		return fmt.Errorf("manifest entry is not a symlink")
+	}
+	target := *e.SymlinkTarget
+	linkPath, err := pathWithin(root, path)
+	if err != nil {
+		return fmt.Errorf("invalid symlink %s: %w", path, err)
+	}
+	if filepath.IsAbs(target) || filepath.VolumeName(target) != "" || strings.HasPrefix(target, "/") {
+		return fmt.Errorf("symlink %s has absolute target %q", path, target)
+	}
+	if _, err := pathWithin(root, filepath.Join(filepath.Dir(path), target)); err != nil {
+		return fmt.Errorf("symlink %s target %q: %w", path, target, err)
+	}
+	if err := checkNoSymlinkParents(root, path); err != nil {
+		return fmt.Errorf("invalid symlink %s: %w", path, err)
+	}
+	if _, err := os.Lstat(linkPath); err == nil {
+		return confineSymlink(root, path)
+	}
+	if err := os.MkdirAll(filepath.Dir(linkPath), 0755); err != nil {
+		return fmt.Errorf("error creating directory for symlink %s: %w", path, err)
+	}
+	if err := os.Symlink(target, linkPath); err != nil {
+		return fmt.Errorf("error creating symlink %s: %w", path, err)
+	}
+	return confineSymlink(root, path)
+}
+
+// confineSymlink resolves the symlink at path, relative to root, and removes
+// it if it leads outside root.
+func confineSymlink(root, path string) error {
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return fmt.Errorf("error resolving %s: %w", root, err)
+	}
+	linkPath := filepath.Join(root, path)
+	resolved, err := resolvePath(linkPath)
+	if err != nil {
+		return fmt.Errorf("error resolving symlink %s: %w", path, err)
+	}
+	if !isWithin(realRoot, resolved) {
+		_ = os.Remove(linkPath)
+		return fmt.Errorf("symlink %s leads outside %s", path, root)
+	}
+	return nil
+}
+
+// maxSymlinkHops bounds the number of links resolvePath follows.
+const maxSymlinkHops = 255
+
+// resolvePath resolves the symlinks in the absolute path p, like
+// filepath.EvalSymlinks, except that components that don't exist are taken
+// as plain directories, so that dangling links can be resolved too.
+func resolvePath(p string) (string, error) {
+	volume := filepath.VolumeName(p)
+	resolved := volume + string(filepath.Separator)
+	parts := splitPath(p[len(volume):])
+	for hops := 0; len(parts) > 0; {
+		part := parts[0]
+		parts = parts[1:]
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+		next := filepath.Join(resolved, part)
+		info, err := os.Lstat(next)
+		if errors.Is(err, os.ErrNotExist) || err == nil && info.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if hops++; hops > maxSymlinkHops {
+			return "", fmt.Errorf("too many links in %s", p)
+		}
+		target, err := os.Readlink(next)
+		if err != nil {
+			return "", err
+		}
+		if filepath.IsAbs(target) {
+			volume := filepath.VolumeName(target)
+			resolved = volume + string(filepath.Separator)
+			target = target[len(volume):]
+		}
+		parts = append(splitPath(target), parts...)
+	}
+	return resolved, nil
+}
+
+// splitPath splits p into its components.
+func splitPath(p string) []string {
+	return strings.FieldsFunc(p, func(r rune) bool { return os.IsPathSeparator(uint8(r)) })
+}
+
+// pathWithin joins root and the relative path rel, and returns an error if
+// the result is outside root.
+func pathWithin(root, rel string) (string, error) {
+	joined := filepath.Join(root, rel)
+	if !isWithin(root, joined) {
+		return "", fmt.Errorf("%s is outside %s", rel, root)
+	}
+	return joined, nil
+}
+
+// isWithin reports whether the path p is root or under it.
+func isWithin(root, p string) bool {
+	relative, err := filepath.Rel(root, p)
+	return err == nil && relative != ".." && !strings.HasPrefix(relative, ".."+string(filepath.Separator))
+}
+
+// checkNoSymlinkParents returns an error if any existing directory between
+// root and the relative path rel is a symlink, which could redirect rel
+// outside root.
+func checkNoSymlinkParents(root, rel string) error {
+	dir := root
+	parts := strings.Split(filepath.Dir(filepath.Clean(rel)), string(filepath.Separator))
+	for _, part := range parts {
+		if part == "." || part == "" {
+			continue
+		}
+		dir = filepath.Join(dir, part)
+		info, err := os.Lstat(dir)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error checking %s: %w", dir, err)
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("parent directory %s is a symlink", dir)
+		}
+	}
+	return nil
+}
+
+// CachePath returns where the entry's contents are stored in the
+// content-addressable artifacts cache rooted at cacheRoot, using the same
+// layout as the Python SDK.
+//
+// Stored entries are keyed by their MD5 digest. References are keyed by a
+// hash of their Ref and Digest, since a reference's digest is usually an ETag
+// rather than a checksum. It returns "" for a stored entry whose digest is
+// not an MD5.
+func (e *ManifestEntry) CachePath(cacheRoot string) string {
+	if e.Ref != nil {
+		refHash := sha256.Sum256([]byte(*e.Ref))
+		digestHash := sha256.Sum256([]byte(e.Digest))
+		hash := sha256.Sum256(append(refHash[:], digestHash[:]...))
+		hexHash := hex.EncodeToString(hash[:])
+		return filepath.Join(cacheRoot, "obj", "etag", hexHash[:2], hexHash[2:])
+	}
+
+	var hexMD5 string
+	switch e.DigestKind() {
+	case DigestKindMD5Base64:
+		hexMD5, _ = utils.B64ToHex(e.Digest)
+	case DigestKindMD5Hex:
+		hexMD5 = strings.ToLower(e.Digest)
+	default:
+		return ""
+	}
+	return filepath.Join(cacheRoot, "obj", "md5", hexMD5[:2], hexMD5[2:])
+}
+
+// IsCached reports whether the artifacts cache rooted at cacheRoot holds a
+// file for the entry with the entry's size. A file of a different size is
+// treated as absent.
+func (e *ManifestEntry) IsCached(cacheRoot string) (bool, error) {
+	path := e.CachePath(cacheRoot)
+	if path == "" {
+		return false, fmt.Errorf("manifest entry digest %q is not an MD5", e.Digest)
+	}
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error checking cache for %s: %w", path, err)
+	}
+	return info.Mode().IsRegular() && info.Size() == e.Size, nil
+}
+
+// RebaseLocalPaths moves every LocalPath under the directory oldPrefix to the
+// same place under newPrefix, as when an artifacts cache directory is moved,
+// and returns the number of paths rewritten. Prefixes match whole path
+// components, so "/cache" does not match "/cache2/x", and a trailing
+// separator on either prefix is ignored. Other entries are left unchanged.
+func (m *Manifest) RebaseLocalPaths(oldPrefix, newPrefix string) int {
+	const separator = string(filepath.Separator)
+	oldPrefix = strings.TrimSuffix(oldPrefix, separator)
+	newPrefix = strings.TrimSuffix(newPrefix, separator)
+	count := 0
+	for path, entry := range m.Contents {
+		if entry.LocalPath == nil {
+			continue
+		}
+		rest, ok := strings.CutPrefix(*entry.LocalPath, oldPrefix)
+		if !ok || rest != "" && !strings.HasPrefix(rest, separator) {
+			continue
+		}
+		localPath := newPrefix + rest
+		entry.LocalPath = &localPath
+		m.Contents[path] = entry
+		count++
+	}
+	return count
+}
@@ -0,0 +1,242 @@
+package artifacts
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wandb/wandb/nexus/pkg/utils"
+)
+
+func TestManifestOpenEntry(t *testing.T) {
+	dir := t.TempDir()
+	present := filepath.Join(dir, "present.txt")
+	missing := filepath.Join(dir, "missing.txt")
+	assert.Nil(t, os.WriteFile(present, []byte("contents"), 0600))
+
+	manifest := Manifest{Contents: map[string]ManifestEntry{
+		"present.txt": {Digest: "a", LocalPath: &present},
+		"missing.txt": {Digest: "b", LocalPath: &missing},
+		"nolocal.txt": {Digest: "c"},
+	}}
+
+	r, err := manifest.OpenEntry("present.txt")
+	assert.Nil(t, err)
+	data, err := io.ReadAll(r)
+	assert.Nil(t, err)
+	assert.Equal(t, "contents", string(data))
+	assert.Nil(t, r.Close())
+
+	_, err = manifest.OpenEntry("missing.txt")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	_, err = manifest.OpenEntry("nolocal.txt")
+	assert.ErrorContains(t, err, "has no local path")
+
+	_, err = manifest.OpenEntry("unknown.txt")
+	assert.ErrorIs(t, err, ErrPathNotFound)
+}
+
+func TestManifestEntryVerifyLocalFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.txt")
+	assert.Nil(t, os.WriteFile(path, []byte("contents"), 0600))
+	digest, err := utils.ComputeB64MD5([]byte("contents"))
+	assert.Nil(t, err)
+
+	entry := ManifestEntry{Digest: digest, Size: 8, LocalPath: &path}
+	assert.Nil(t, entry.VerifyLocalFile())
+
+	assert.Nil(t, os.WriteFile(path, []byte("corrupted"), 0600))
+	assert.ErrorIs(t, entry.VerifyLocalFile(), ErrDigestMismatch)
+
+	ref := "s3://bucket/file.txt"
+	reference := ManifestEntry{Digest: "etag", Ref: &ref, LocalPath: &path}
+	assert.Nil(t, reference.VerifyLocalFile())
+
+	noLocal := ManifestEntry{Digest: digest}
+	assert.ErrorContains(t, noLocal.VerifyLocalFile(), "no local path")
+}
+
+func TestManifestEntryCreateSymlink(t *testing.T) {
+	dir := t.TempDir()
+	target := "../real.txt"
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "real.txt"), []byte("contents"), 0600))
+
+	entry := ManifestEntry{Digest: "abc", SymlinkTarget: &target}
+	path := filepath.Join(dir, "nested", "link.txt")
+	assert.Nil(t, entry.CreateSymlink(dir, filepath.Join("nested", "link.txt")))
+	linked, err := os.Readlink(path)
+	assert.Nil(t, err)
+	assert.Equal(t, target, linked)
+	data, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "contents", string(data))
+
+	// Recreating an existing link is a no-op.
+	assert.Nil(t, entry.CreateSymlink(dir, filepath.Join("nested", "link.txt")))
+
+	regular := ManifestEntry{Digest: "abc"}
+	assert.ErrorContains(t, regular.CreateSymlink(dir, "other"), "not a symlink")
+}
+
+func TestManifestEntryCreateSymlinkRejectsHostileTargets(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "root")
+	assert.Nil(t, os.MkdirAll(root, 0755))
+
+	for _, tt := range []struct {
+		path, target, wantErr string
+	}{
+		{"link", "/etc", "absolute target"},
+		{"link", "..", "outside"},
+		{"a/b/link", "../../..", "outside"},
+		{"link", "../root-sibling/file", "outside"},
+		{"../link", "root/file", "outside"},
+	} {
+		target := tt.target
+		entry := ManifestEntry{Digest: "abc", SymlinkTarget: &target}
+		err := entry.CreateSymlink(root, filepath.FromSlash(tt.path))
+		assert.ErrorContains(t, err, tt.wantErr, "%s -> %s", tt.path, tt.target)
+		_, statErr := os.Lstat(filepath.Join(root, filepath.FromSlash(tt.path)))
+		assert.True(t, os.IsNotExist(statErr), "%s should not exist", tt.path)
+	}
+
+	// Each link stays inside root on its own, but creating the second one
+	// through the first would place it, and its target, outside root.
+	up, dotdot := ".", ".."
+	first := ManifestEntry{Digest: "abc", SymlinkTarget: &up}
+	assert.Nil(t, first.CreateSymlink(root, filepath.Join("dir", "self")))
+	second := ManifestEntry{Digest: "abc", SymlinkTarget: &dotdot}
+	err := second.CreateSymlink(root, filepath.Join("dir", "self", "escape"))
+	assert.ErrorContains(t, err, "is a symlink")
+}
+
+func TestManifestEntryCreateSymlinkRejectsChains(t *testing.T) {
+	parent := t.TempDir()
+	root := filepath.Join(parent, "root")
+	assert.Nil(t, os.MkdirAll(root, 0755))
+	assert.Nil(t, os.WriteFile(filepath.Join(parent, "secret"), []byte("secret"), 0600))
+
+	// Each target stays inside root on its own, but sub/x leads through l,
+	// which points at root, and then up out of it.
+	self, escape := ".", "../l/../secret"
+	l := ManifestEntry{Digest: "abc", SymlinkTarget: &self}
+	x := ManifestEntry{Digest: "abc", SymlinkTarget: &escape}
+	assert.Nil(t, l.CreateSymlink(root, "l"))
+	err := x.CreateSymlink(root, filepath.Join("sub", "x"))
+	assert.ErrorContains(t, err, "leads outside")
+	_, err = os.Lstat(filepath.Join(root, "sub", "x"))
+	assert.True(t, os.IsNotExist(err))
+
+	// In the other order, sub/x is harmless when created, and only escapes
+	// once l exists.
+	other := filepath.Join(parent, "other")
+	assert.Nil(t, os.MkdirAll(other, 0755))
+	assert.Nil(t, x.CreateSymlink(other, filepath.Join("sub", "x")))
+	assert.Nil(t, l.CreateSymlink(other, "l"))
+	err = confineSymlink(other, filepath.Join("sub", "x"))
+	assert.ErrorContains(t, err, "leads outside")
+	_, err = os.Lstat(filepath.Join(other, "sub", "x"))
+	assert.True(t, os.IsNotExist(err))
+	assert.Nil(t, confineSymlink(other, "l"))
+}
+
+func TestManifestEntryCachePath(t *testing.T) {
+	cacheRoot := t.TempDir()
+	// The base64 and hex encodings of the MD5 of "contents".
+	entry := ManifestEntry{Digest: "mL99jBV4Two9YyBEQeHiqg==", Size: 8}
+	expected := filepath.Join(cacheRoot, "obj", "md5", "98", "bf7d8c15784f0a3d63204441e1e2aa")
+	assert.Equal(t, expected, entry.CachePath(cacheRoot))
+	hexEntry := ManifestEntry{Digest: "98bf7d8c15784f0a3d63204441e1e2aa", Size: 8}
+	assert.Equal(t, expected, hexEntry.CachePath(cacheRoot))
+
+	ref := "s3://bucket/key"
+	reference := ManifestEntry{Digest: "etag", Ref: &ref}
+	assert.Contains(t, reference.CachePath(cacheRoot), filepath.Join(cacheRoot, "obj", "etag"))
+
+	unknown := ManifestEntry{Digest: "not-an-md5"}
+	assert.Equal(t, "", unknown.CachePath(cacheRoot))
+	_, err := unknown.IsCached(cacheRoot)
+	assert.ErrorContains(t, err, "not an MD5")
+}
+
+func TestManifestEntryIsCached(t *testing.T) {
+	cacheRoot := t.TempDir()
+	entry := ManifestEntry{Digest: "mL99jBV4Two9YyBEQeHiqg==", Size: 8}
+
+	cached, err := entry.IsCached(cacheRoot)
+	assert.Nil(t, err)
+	assert.False(t, cached)
+
+	path := entry.CachePath(cacheRoot)
+	assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+	assert.Nil(t, os.WriteFile(path, []byte("contents"), 0600))
+	cached, err = entry.IsCached(cacheRoot)
+	assert.Nil(t, err)
+	assert.True(t, cached)
+
+	assert.Nil(t, os.WriteFile(path, []byte("partial"), 0600))
+	cached, err = entry.IsCached(cacheRoot)
+	assert.Nil(t, err)
+	assert.False(t, cached)
+}
+
+func TestManifestRebaseLocalPaths(t *testing.T) {
+	inCache, elsewhere := "/old/cache/a.txt", "/other/b.txt"
+	shared, sibling := "/old/cache/c.txt", "/old/cache2/e.txt"
+	manifest := Manifest{Contents: map[string]ManifestEntry{
+		"a.txt": {Digest: "a", LocalPath: &inCache},
+		"b.txt": {Digest: "b", LocalPath: &elsewhere},
+		"c.txt": {Digest: "c", LocalPath: &shared},
+		"d.txt": {Digest: "d"},
+		"e.txt": {Digest: "e", LocalPath: &sibling},
+	}}
+
+	assert.Equal(t, 2, manifest.RebaseLocalPaths("/old/cache", "/new/cache"))
+	assert.Equal(t, "/new/cache/a.txt", *manifest.Contents["a.txt"].LocalPath)
+	assert.Equal(t, "/new/cache/c.txt", *manifest.Contents["c.txt"].LocalPath)
+	assert.Equal(t, "/other/b.txt", *manifest.Contents["b.txt"].LocalPath)
+	assert.Nil(t, manifest.Contents["d.txt"].LocalPath)
+	assert.Equal(t, "/old/cache2/e.txt", *manifest.Contents["e.txt"].LocalPath)
+
+	// Trailing separators don't matter.
+	assert.Equal(t, 2, manifest.RebaseLocalPaths("/new/cache/", "/newer/cache/"))
+	assert.Equal(t, "/newer/cache/a.txt", *manifest.Contents["a.txt"].LocalPath)
+
+	// The original strings are not modified.
+	assert.Equal(t, "/old/cache/c.txt", shared)
+	assert.Equal(t, 0, manifest.RebaseLocalPaths("/old/cache", "/new/cache"))
+
+	exact := "/old/cache"
+	manifest.Contents["f"] = ManifestEntry{Digest: "f", LocalPath: &exact}
+	assert.Equal(t, 1, manifest.RebaseLocalPaths("/old/cache", "/new/cache"))
+	assert.Equal(t, "/new/cache", *manifest.Contents["f"].LocalPath)
+}
+
+func TestEntryFromLocalFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file.txt")
+	assert.Nil(t, os.WriteFile(path, []byte("contents"), 0600))
+
+	entry, err := EntryFromLocalFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "mL99jBV4Two9YyBEQeHiqg==", entry.Digest)
+	assert.Equal(t, int64(8), entry.Size)
+	assert.Equal(t, path, *entry.LocalPath)
+	assert.Nil(t, entry.VerifyLocalFile())
+
+	emptyPath := filepath.Join(dir, "empty.txt")
+	assert.Nil(t, os.WriteFile(emptyPath, nil, 0600))
+	empty, err := EntryFromLocalFile(emptyPath)
+	assert.Nil(t, err)
+	// The MD5 of no bytes.
+	assert.Equal(t, "1B2M2Y8AsgTpgAmY7PhCfg==", empty.Digest)
+	assert.Equal(t, int64(0), empty.Size)
+
+	_, err = EntryFromLocalFile(filepath.Join(dir, "missing.txt"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	_, err = EntryFromLocalFile(dir)
+	assert.ErrorContains(t, err, "not a regular file")
+}
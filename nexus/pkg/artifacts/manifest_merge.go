@@ -0,0 +1,72 @@
+package artifacts
+
+import (
+	"fmt"
+)
+
+// Merge copies other's contents into m.
+//
+// Both manifests must have the same version and storage policy. A path that
+// appears in both manifests is accepted only if the two entries are
+// duplicates, in which case m's entry is kept: stored entries must have the
+// same digest, and reference entries the same Ref. The same Ref under
+// different paths is not a conflict. On error, m is left unchanged.
+func (m *Manifest) Merge(other *Manifest) error {
+	if m.Version != other.Version {
+		return fmt.Errorf(
+			"cannot merge manifests with different versions: %d and %d",
+			m.Version, other.Version,
+		)
+	}
+	if m.StoragePolicy != other.StoragePolicy {
+		return fmt.Errorf(
+			"cannot merge manifests with different storage policies: %q and %q",
+			m.StoragePolicy, other.StoragePolicy,
+		)
+	}
+
+	for path, entry := range other.Contents {
+		existing, ok := m.Contents[path]
+		if !ok {
+			continue
+		}
+		if err := checkMergeDuplicate(path, existing, entry); err != nil {
+			return fmt.Errorf("cannot merge manifests: %w", err)
+		}
+	}
+
+	if m.Contents == nil {
+		m.Contents = make(map[string]ManifestEntry, len(other.Contents))
+	}
+	for path, entry := range other.Contents {
+		if _, ok := m.Contents[path]; !ok {
+			m.Contents[path] = entry
+		}
+	}
+	return nil
+}
+
+// checkMergeDuplicate returns an error unless existing and entry, which are
+// both stored at path, describe the same object.
+//
+// References are compared by Ref rather than by digest, since linked
+// artifacts may record the same object with or without its ETag.
+func checkMergeDuplicate(path string, existing, entry ManifestEntry) error {
+	switch {
+	case existing.IsReference() != entry.IsReference():
+		return fmt.Errorf("%q is a reference in only one manifest", path)
+	case existing.IsReference():
+		if *existing.Ref != *entry.Ref {
+			return fmt.Errorf(
+				"conflicting references for %q: %s and %s",
+				path, *existing.Ref, *entry.Ref,
+			)
+		}
+	case existing.Digest != entry.Digest:
+		return fmt.Errorf(
+			"conflicting digests for %q: %s and %s",
+			path, existing.Digest, entry.Digest,
+		)
+	}
+	return nil
+}
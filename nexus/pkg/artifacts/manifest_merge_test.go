@@ -0,0 +1,92 @@
+package artifacts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newMergeTestManifest(contents map[string]ManifestEntry) Manifest {
+	return Manifest{
+		Version:       1,
+		StoragePolicy: "wandb-storage-policy-v1",
+		Contents:      contents,
+	}
+}
+
+func TestManifestMerge(t *testing.T) {
+	m := newMergeTestManifest(map[string]ManifestEntry{
+		"a.txt":      {Digest: "a", Size: 1},
+		"shared.txt": {Digest: "s", Size: 1},
+	})
+	other := newMergeTestManifest(map[string]ManifestEntry{
+		"b.txt":      {Digest: "b", Size: 2},
+		"shared.txt": {Digest: "s", Size: 1},
+	})
+
+	assert.Nil(t, m.Merge(&other))
+	assert.Equal(t,
+		map[string]ManifestEntry{
+			"a.txt":      {Digest: "a", Size: 1},
+			"b.txt":      {Digest: "b", Size: 2},
+			"shared.txt": {Digest: "s", Size: 1},
+		},
+		m.Contents,
+	)
+}
+
+func TestManifestMergeConflict(t *testing.T) {
+	m := newMergeTestManifest(map[string]ManifestEntry{
+		"a.txt": {Digest: "a", Size: 1},
+	})
+	other := newMergeTestManifest(map[string]ManifestEntry{
+		"a.txt": {Digest: "A", Size: 1},
+		"b.txt": {Digest: "b", Size: 1},
+	})
+
+	assert.ErrorContains(t, m.Merge(&other), `conflicting digests for "a.txt"`)
+	assert.Len(t, m.Contents, 1)
+}
+
+func TestManifestMergeMismatchedPolicy(t *testing.T) {
+	m := newMergeTestManifest(nil)
+	other := newMergeTestManifest(nil)
+	other.StoragePolicy = "other-policy"
+	assert.ErrorContains(t, m.Merge(&other), "different storage policies")
+
+	other = newMergeTestManifest(nil)
+	other.Version = 2
+	assert.ErrorContains(t, m.Merge(&other), "different versions")
+}
+
+func TestManifestMergeReferences(t *testing.T) {
+	ref, refCopy, otherRef := "s3://bucket/key", "s3://bucket/key", "s3://bucket/other"
+	m := newMergeTestManifest(map[string]ManifestEntry{
+		"ref.txt": {Ref: &ref, Digest: "etag", Size: 1},
+	})
+
+	samePath := newMergeTestManifest(map[string]ManifestEntry{
+		"ref.txt": {Ref: &refCopy, Size: 1},
+	})
+	assert.Nil(t, m.Merge(&samePath))
+	assert.Len(t, m.Contents, 1)
+	assert.Equal(t, "etag", m.Contents["ref.txt"].Digest)
+
+	otherPath := newMergeTestManifest(map[string]ManifestEntry{
+		"copy.txt": {Ref: &refCopy, Digest: "etag", Size: 1},
+	})
+	assert.Nil(t, m.Merge(&otherPath))
+	assert.Len(t, m.Contents, 2)
+	assert.Equal(t, ref, *m.Contents["copy.txt"].Ref)
+
+	conflicting := newMergeTestManifest(map[string]ManifestEntry{
+		"ref.txt": {Ref: &otherRef, Digest: "etag", Size: 1},
+	})
+	assert.ErrorContains(t, m.Merge(&conflicting), `conflicting references for "ref.txt"`)
+
+	stored := newMergeTestManifest(map[string]ManifestEntry{
+		"ref.txt": {Digest: "etag", Size: 1},
+	})
+	assert.ErrorContains(t, m.Merge(&stored), "reference in only one manifest")
+	assert.Len(t, m.Contents, 2)
+}
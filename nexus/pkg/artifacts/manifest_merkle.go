@@ -0,0 +1,67 @@
+package artifacts
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+)
+
+// Prefixes that distinguish leaf hashes from interior node hashes in
+// MerkleRoot, as in RFC 6962, so that a node can't be passed off as a leaf.
+const (
+	merkleLeafPrefix = 0x00
+	merkleNodePrefix = 0x01
+)
+
+// MerkleRoot returns the hex-encoded SHA256 root of a binary Merkle tree
+// whose leaves are the manifest's (path, digest) pairs in sorted path order.
+//
+// Each leaf hashes the length-prefixed path followed by the digest. Each
+// level pairs adjacent nodes, and a node without a partner is carried up to
+// the next level unchanged. The root of an empty manifest is the SHA256 of no
+// input. It is an error for an entry to have no digest.
+func (m *Manifest) MerkleRoot() (string, error) {
+	paths := m.SortedPaths()
+	if len(paths) == 0 {
+		root := sha256.Sum256(nil)
+		return hex.EncodeToString(root[:]), nil
+	}
+
+	level := make([][]byte, 0, len(paths))
+	for _, path := range paths {
+		entry := m.Contents[path]
+		if entry.Digest == "" {
+			return "", fmt.Errorf("manifest entry %q has no digest", path)
+		}
+		level = append(level, merkleLeaf(path, entry.Digest))
+	}
+	for len(level) > 1 {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i+1 < len(level); i += 2 {
+			next = append(next, merkleNode(level[i], level[i+1]))
+		}
+		if len(level)%2 == 1 {
+			next = append(next, level[len(level)-1])
+		}
+		level = next
+	}
+	return hex.EncodeToString(level[0]), nil
+}
+
+func merkleLeaf(path, digest string) []byte {
+	hasher := sha256.New()
+	hasher.Write([]byte{merkleLeafPrefix})
+	_ = binary.Write(hasher, binary.BigEndian, uint64(len(path)))
+	hasher.Write([]byte(path))
+	hasher.Write([]byte(digest))
+	return hasher.Sum(nil)
+}
+
+func merkleNode(left, right []byte) []byte {
+	hasher := sha256.New()
+	hasher.Write([]byte{merkleNodePrefix})
+	hasher.Write(left)
+	hasher.Write(right)
+	return hasher.Sum(nil)
+}
@@ -0,0 +1,73 @@
+package artifacts
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManifestMerkleRoot(t *testing.T) {
+	newManifest := func(paths ...string) Manifest {
+		manifest := Manifest{Contents: map[string]ManifestEntry{}}
+		for _, path := range paths {
+			manifest.Contents[path] = ManifestEntry{Digest: "digest-" + path, Size: 1}
+		}
+		return manifest
+	}
+
+	// Roots must not depend on the order in which entries were added.
+	forward := newManifest("a.txt", "b.txt", "c.txt")
+	backward := newManifest("c.txt", "b.txt", "a.txt")
+	root, err := forward.MerkleRoot()
+	assert.Nil(t, err)
+	backwardRoot, err := backward.MerkleRoot()
+	assert.Nil(t, err)
+	assert.Equal(t, root, backwardRoot)
+
+	for _, path := range []string{"a.txt", "b.txt", "c.txt"} {
+		changed := newManifest("a.txt", "b.txt", "c.txt")
+		entry := changed.Contents[path]
+		entry.Digest = "tampered"
+		changed.Contents[path] = entry
+		changedRoot, err := changed.MerkleRoot()
+		assert.Nil(t, err)
+		assert.NotEqual(t, root, changedRoot, path)
+	}
+
+	// Moving a digest to another path changes the root too.
+	renamed := newManifest("a.txt", "b.txt")
+	renamed.Contents["renamed.txt"] = ManifestEntry{Digest: "digest-c.txt", Size: 1}
+	renamedRoot, err := renamed.MerkleRoot()
+	assert.Nil(t, err)
+	assert.NotEqual(t, root, renamedRoot)
+}
+
+func TestManifestMerkleRootShapes(t *testing.T) {
+	empty := Manifest{}
+	root, err := empty.MerkleRoot()
+	assert.Nil(t, err)
+	emptyHash := sha256.Sum256(nil)
+	assert.Equal(t, hex.EncodeToString(emptyHash[:]), root)
+
+	single := Manifest{Contents: map[string]ManifestEntry{"a.txt": {Digest: "x"}}}
+	root, err = single.MerkleRoot()
+	assert.Nil(t, err)
+	assert.Equal(t, hex.EncodeToString(merkleLeaf("a.txt", "x")), root)
+
+	roots := map[string]bool{}
+	manifest := Manifest{Contents: map[string]ManifestEntry{}}
+	for i := 0; i < 9; i++ {
+		manifest.Contents[fmt.Sprintf("file-%d", i)] = ManifestEntry{Digest: "same"}
+		root, err := manifest.MerkleRoot()
+		assert.Nil(t, err)
+		roots[root] = true
+	}
+	assert.Len(t, roots, 9)
+
+	missing := Manifest{Contents: map[string]ManifestEntry{"ref.txt": {}}}
+	_, err = missing.MerkleRoot()
+	assert.ErrorContains(t, err, `manifest entry "ref.txt" has no digest`)
+}
@@ -0,0 +1,45 @@
+package artifacts
+
+import (
+	"fmt"
+)
+
+// ManifestPatch describes how one manifest differs from another by the
+// entries to add or replace and the paths to remove.
+type ManifestPatch struct {
+	Upsert map[string]ManifestEntry `json:"upsert,omitempty"`
+	Delete []string                 `json:"delete,omitempty"`
+}
+
+// ApplyPatch modifies m in place by removing the paths in patch.Delete and
+// then adding or replacing the entries in patch.Upsert.
+//
+// It is an error to delete a path that is not in m, to both delete and
+// upsert the same path, or to upsert an invalid entry. On error, m is left
+// unchanged.
+func (m *Manifest) ApplyPatch(patch ManifestPatch) error {
+	for _, path := range patch.Delete {
+		if _, ok := m.Contents[path]; !ok {
+			return fmt.Errorf("cannot apply manifest patch: %w: %s", ErrPathNotFound, path)
+		}
+		if _, ok := patch.Upsert[path]; ok {
+			return fmt.Errorf("cannot apply manifest patch: %q is both deleted and upserted", path)
+		}
+	}
+	for path, entry := range patch.Upsert {
+		if err := entry.Validate(); err != nil {
+			return fmt.Errorf("cannot apply manifest patch: manifest entry %q: %w", path, err)
+		}
+	}
+
+	for _, path := range patch.Delete {
+		delete(m.Contents, path)
+	}
+	if m.Contents == nil {
+		m.Contents = make(map[string]ManifestEntry, len(patch.Upsert))
+	}
+	for path, entry := range patch.Upsert {
+		m.Contents[path] = entry
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package artifacts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManifestApplyPatch(t *testing.T) {
+	manifest := Manifest{Contents: map[string]ManifestEntry{
+		"keep.txt":    {Digest: "k", Size: 1},
+		"replace.txt": {Digest: "old", Size: 2},
+		"remove.txt":  {Digest: "r", Size: 3},
+	}}
+
+	err := manifest.ApplyPatch(ManifestPatch{
+		Upsert: map[string]ManifestEntry{
+			"replace.txt": {Digest: "new", Size: 4},
+			"add.txt":     {Digest: "a", Size: 5},
+		},
+		Delete: []string{"remove.txt"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]ManifestEntry{
+		"keep.txt":    {Digest: "k", Size: 1},
+		"replace.txt": {Digest: "new", Size: 4},
+		"add.txt":     {Digest: "a", Size: 5},
+	}, manifest.Contents)
+
+	empty := Manifest{}
+	assert.Nil(t, empty.ApplyPatch(ManifestPatch{Upsert: map[string]ManifestEntry{"a.txt": {Digest: "a"}}}))
+	assert.Len(t, empty.Contents, 1)
+}
+
+func TestManifestApplyPatchErrors(t *testing.T) {
+	manifest := Manifest{Contents: map[string]ManifestEntry{"a.txt": {Digest: "a"}}}
+	before := Manifest{Contents: map[string]ManifestEntry{"a.txt": {Digest: "a"}}}
+
+	err := manifest.ApplyPatch(ManifestPatch{
+		Upsert: map[string]ManifestEntry{"b.txt": {Digest: "b"}},
+		Delete: []string{"missing.txt"},
+	})
+	assert.ErrorIs(t, err, ErrPathNotFound)
+	assert.Equal(t, before, manifest)
+
+	err = manifest.ApplyPatch(ManifestPatch{
+		Upsert: map[string]ManifestEntry{"a.txt": {Digest: "b"}},
+		Delete: []string{"a.txt"},
+	})
+	assert.ErrorContains(t, err, `"a.txt" is both deleted and upserted`)
+	assert.Equal(t, before, manifest)
+
+	err = manifest.ApplyPatch(ManifestPatch{
+		Upsert: map[string]ManifestEntry{"b.txt": {}},
+		Delete: []string{"a.txt"},
+	})
+	assert.ErrorContains(t, err, "missing digest")
+	assert.Equal(t, before, manifest)
+}
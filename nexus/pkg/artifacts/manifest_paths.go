@@ -0,0 +1,137 @@
+package artifacts
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// normalizeArtifactPath converts p into the canonical form used for paths
+// inside an artifact: forward slashes, no redundant or "." segments, and no
+// leading "./". A trailing "/", which marks a directory placeholder, is kept.
+func normalizeArtifactPath(p string) string {
+	p = strings.ReplaceAll(p, "\\", "/")
+	cleaned := path.Clean(p)
+	if strings.HasSuffix(p, "/") && cleaned != "/" && cleaned != "." {
+		cleaned += "/"
+	}
+	return cleaned
+}
+
+// GetEntryCaseInsensitive is like GetManifestEntryFromArtifactFilePath, but if
+// no path matches exactly, it looks for one that differs only in case, as on
+// case-insensitive filesystems.
+//
+// It is an error if several paths differ from path only in case.
+func (m *Manifest) GetEntryCaseInsensitive(path string) (ManifestEntry, error) {
+	entry, err := m.GetManifestEntryFromArtifactFilePath(path)
+	if !errors.Is(err, ErrPathNotFound) {
+		return entry, err
+	}
+
+	normalized := normalizeArtifactPath(path)
+	var matches []string
+	for key := range m.Contents {
+		if strings.EqualFold(normalizeArtifactPath(key), normalized) {
+			matches = append(matches, key)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return ManifestEntry{}, err
+	case 1:
+		return m.Contents[matches[0]], nil
+	default:
+		sort.Strings(matches)
+		return ManifestEntry{}, fmt.Errorf(
+			"path %s matches several paths in artifact when ignoring case: %s",
+			path, strings.Join(matches, ", "),
+		)
+	}
+}
+
+// GetEntries looks up each of paths as GetManifestEntryFromArtifactFilePath
+// does, and returns the entries found, keyed by the path they were requested
+// with, along with the paths that were not found or were ambiguous, in the
+// order given.
+func (m *Manifest) GetEntries(paths []string) (map[string]ManifestEntry, []string) {
+	found := make(map[string]ManifestEntry, len(paths))
+	var missing []string
+	var index *pathIndex
+	for _, p := range paths {
+		if entry, ok := m.Contents[p]; ok {
+			found[p] = entry
+			continue
+		}
+		if index == nil {
+			// Index the normalized keys once, rather than scanning the
+			// manifest for every miss.
+			index = newPathIndex(m.Contents)
+		}
+		if entry, err := index.get(p); err == nil {
+			found[p] = entry
+		} else {
+			missing = append(missing, p)
+		}
+	}
+	return found, missing
+}
+
+// pathIndex looks up entries by normalized path without scanning the
+// manifest for each lookup. It must be rebuilt if the contents change.
+type pathIndex struct {
+	contents map[string]ManifestEntry
+
+	// keys maps each normalized path to the sorted stored paths that
+	// normalize to it.
+	keys map[string][]string
+}
+
+func newPathIndex(contents map[string]ManifestEntry) *pathIndex {
+	keys := make(map[string][]string, len(contents))
+	for key := range contents {
+		normalized := normalizeArtifactPath(key)
+		keys[normalized] = append(keys[normalized], key)
+	}
+	for _, stored := range keys {
+		sort.Strings(stored)
+	}
+	return &pathIndex{contents: contents, keys: keys}
+}
+
+// get returns the entry stored at path verbatim, or else the one stored at a
+// path that normalizes to the same path as path. It is an error if several
+// do.
+func (i *pathIndex) get(path string) (ManifestEntry, error) {
+	if entry, ok := i.contents[path]; ok {
+		return entry, nil
+	}
+	stored := i.keys[normalizeArtifactPath(path)]
+	switch len(stored) {
+	case 0:
+		return ManifestEntry{}, fmt.Errorf("%w: %s", ErrPathNotFound, path)
+	case 1:
+		return i.contents[stored[0]], nil
+	default:
+		return ManifestEntry{}, fmt.Errorf(
+			"path %s matches several paths in artifact: %s",
+			path, strings.Join(stored, ", "),
+		)
+	}
+}
+
+// PathsForDigest returns the sorted paths of all entries with the given
+// digest, such as to find every copy of a known-bad file. It returns nil if
+// no entry has the digest.
+func (m *Manifest) PathsForDigest(digest string) []string {
+	var paths []string
+	for path, entry := range m.Contents {
+		if entry.Digest == digest {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
@@ -0,0 +1,145 @@
+package artifacts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetManifestEntryNormalizesPaths(t *testing.T) {
+	manifest := Manifest{Contents: map[string]ManifestEntry{
+		"foo/bar.txt":     {Digest: "bar"},
+		"./dir//baz.json": {Digest: "baz"},
+	}}
+
+	for _, lookup := range []string{
+		"foo/bar.txt",
+		"./foo/bar.txt",
+		"foo//bar.txt",
+		"foo/./bar.txt",
+		"foo/qux/../bar.txt",
+		`foo\bar.txt`,
+		`.\foo\bar.txt`,
+	} {
+		entry, err := manifest.GetManifestEntryFromArtifactFilePath(lookup)
+		assert.Nil(t, err, lookup)
+		assert.Equal(t, "bar", entry.Digest, lookup)
+	}
+
+	entry, err := manifest.GetManifestEntryFromArtifactFilePath(`dir\baz.json`)
+	assert.Nil(t, err)
+	assert.Equal(t, "baz", entry.Digest)
+
+	_, err = manifest.GetManifestEntryFromArtifactFilePath("foo/missing.txt")
+	assert.ErrorContains(t, err, "path not contained in artifact")
+}
+
+func TestGetManifestEntryAmbiguousNormalization(t *testing.T) {
+	manifest := Manifest{Contents: map[string]ManifestEntry{
+		"./dir/file.txt": {Digest: "a"},
+		"dir//file.txt":  {Digest: "b"},
+		"other.txt":      {Digest: "c"},
+	}}
+
+	// Stored paths are still found verbatim.
+	entry, err := manifest.GetManifestEntryFromArtifactFilePath("dir//file.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, "b", entry.Digest)
+
+	for i := 0; i < 10; i++ {
+		_, err = manifest.GetManifestEntryFromArtifactFilePath("dir/file.txt")
+		assert.ErrorContains(t, err, "path dir/file.txt matches several paths in artifact: ./dir/file.txt, dir//file.txt")
+		assert.NotErrorIs(t, err, ErrPathNotFound)
+	}
+
+	found, missing := manifest.GetEntries([]string{"dir/file.txt", "./other.txt"})
+	assert.Equal(t, map[string]ManifestEntry{"./other.txt": {Digest: "c"}}, found)
+	assert.Equal(t, []string{"dir/file.txt"}, missing)
+}
+
+func TestGetManifestEntryDirectoryPlaceholder(t *testing.T) {
+	manifest := Manifest{Contents: map[string]ManifestEntry{
+		"a":   {Digest: "file"},
+		"a/":  {Digest: emptyMD5Digest},
+		"b//": {Digest: emptyMD5Digest},
+	}}
+
+	for lookup, digest := range map[string]string{
+		"./a":  "file",
+		"./a/": emptyMD5Digest,
+		`.\a\`: emptyMD5Digest,
+		"a//":  emptyMD5Digest,
+		"./b/": emptyMD5Digest,
+	} {
+		entry, err := manifest.GetManifestEntryFromArtifactFilePath(lookup)
+		assert.Nil(t, err, lookup)
+		assert.Equal(t, digest, entry.Digest, lookup)
+	}
+	_, err := manifest.GetManifestEntryFromArtifactFilePath("./b")
+	assert.ErrorIs(t, err, ErrPathNotFound)
+}
+
+func TestManifestGetEntryCaseInsensitive(t *testing.T) {
+	manifest := Manifest{Contents: map[string]ManifestEntry{
+		"Data/Train.CSV": {Digest: "train"},
+		"readme.md":      {Digest: "lower"},
+		"README.md":      {Digest: "upper"},
+	}}
+
+	entry, err := manifest.GetEntryCaseInsensitive("data/train.csv")
+	assert.Nil(t, err)
+	assert.Equal(t, "train", entry.Digest)
+	entry, err = manifest.GetEntryCaseInsensitive(`.\DATA\train.csv`)
+	assert.Nil(t, err)
+	assert.Equal(t, "train", entry.Digest)
+
+	// An exact match wins over case variants.
+	entry, err = manifest.GetEntryCaseInsensitive("README.md")
+	assert.Nil(t, err)
+	assert.Equal(t, "upper", entry.Digest)
+
+	_, err = manifest.GetEntryCaseInsensitive("Readme.md")
+	assert.ErrorContains(t, err, "matches several paths in artifact when ignoring case: README.md, readme.md")
+
+	_, err = manifest.GetEntryCaseInsensitive("missing.txt")
+	assert.ErrorIs(t, err, ErrPathNotFound)
+
+	// The default lookup stays case-sensitive.
+	_, err = manifest.GetManifestEntryFromArtifactFilePath("data/train.csv")
+	assert.ErrorIs(t, err, ErrPathNotFound)
+}
+
+func TestManifestGetEntries(t *testing.T) {
+	manifest := Manifest{Contents: map[string]ManifestEntry{
+		"a.txt":       {Digest: "a"},
+		"dir/b.txt":   {Digest: "b"},
+		"./odd//c.md": {Digest: "c"},
+	}}
+
+	found, missing := manifest.GetEntries([]string{
+		"a.txt", "missing.txt", `dir\b.txt`, "odd/c.md", "dir/missing.txt",
+	})
+	assert.Equal(t, map[string]ManifestEntry{
+		"a.txt":     {Digest: "a"},
+		`dir\b.txt`: {Digest: "b"},
+		"odd/c.md":  {Digest: "c"},
+	}, found)
+	assert.Equal(t, []string{"missing.txt", "dir/missing.txt"}, missing)
+
+	found, missing = manifest.GetEntries(nil)
+	assert.Empty(t, found)
+	assert.Empty(t, missing)
+}
+
+func TestManifestPathsForDigest(t *testing.T) {
+	manifest := Manifest{Contents: map[string]ManifestEntry{
+		"b/copy.txt":     {Digest: "shared", Size: 1},
+		"a/original.txt": {Digest: "shared", Size: 1},
+		"c/copy.txt":     {Digest: "shared", Size: 1},
+		"unique.txt":     {Digest: "unique", Size: 2},
+	}}
+	assert.Equal(t, []string{"a/original.txt", "b/copy.txt", "c/copy.txt"}, manifest.PathsForDigest("shared"))
+	assert.Equal(t, []string{"unique.txt"}, manifest.PathsForDigest("unique"))
+	assert.Nil(t, manifest.PathsForDigest("unknown"))
+	assert.Nil(t, manifest.PathsForDigest(""))
+}
@@ -0,0 +1,105 @@
+package artifacts
+
+import (
+	"os"
+	"sort"
+)
+
+// DownloadPlan classifies a manifest's entries by how they will be obtained.
+// Each slice holds paths in sorted order.
+type DownloadPlan struct {
+	// Download holds stored entries that must be fetched from W&B, normally
+	// via their DownloadURL.
+	Download []string
+
+	// References holds entries that refer to external objects.
+	References []string
+
+	// Local holds stored entries whose LocalPath already exists on disk.
+	Local []string
+
+	// Directories holds directory placeholders, which are created rather
+	// than downloaded.
+	Directories []string
+
+	// DownloadBytes is the total size of the entries in Download.
+	DownloadBytes int64
+}
+
+// DownloadPlan returns a plan for materializing the manifest.
+func (m *Manifest) DownloadPlan() DownloadPlan {
+	var plan DownloadPlan
+	for _, path := range m.SortedPaths() {
+		entry := m.Contents[path]
+		switch {
+		case entry.Ref != nil:
+			plan.References = append(plan.References, path)
+		case entry.IsDir(path):
+			plan.Directories = append(plan.Directories, path)
+		case entry.LocalPath != nil && fileExists(*entry.LocalPath):
+			plan.Local = append(plan.Local, path)
+		default:
+			plan.Download = append(plan.Download, path)
+			plan.DownloadBytes = addSizes(plan.DownloadBytes, entry.Size)
+		}
+	}
+	return plan
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// OrderStrategy determines the order in which entries are downloaded.
+type OrderStrategy int
+
+const (
+	// Lexical orders entries by path.
+	Lexical OrderStrategy = iota
+	// SmallestFirst orders entries by increasing size, which keeps progress
+	// updates frequent.
+	SmallestFirst
+	// LargestFirst orders entries by decreasing size, which starts the
+	// longest transfers early.
+	LargestFirst
+)
+
+// OrderedPaths returns the manifest's paths in the order given by strategy.
+// Entries of equal size are ordered by path.
+func (m *Manifest) OrderedPaths(strategy OrderStrategy) []string {
+	paths := m.SortedPaths()
+	switch strategy {
+	case SmallestFirst:
+		sort.SliceStable(paths, func(i, j int) bool {
+			return m.Contents[paths[i]].Size < m.Contents[paths[j]].Size
+		})
+	case LargestFirst:
+		sort.SliceStable(paths, func(i, j int) bool {
+			return m.Contents[paths[i]].Size > m.Contents[paths[j]].Size
+		})
+	}
+	return paths
+}
+
+// PlanWithinBudget returns the paths of the entries to download, in the
+// order given by strategy, such that their sizes add up to at most maxBytes,
+// along with that total. Entries are taken in order until the next one would
+// exceed the budget.
+//
+// Reference entries are always excluded: their bytes are not stored by W&B,
+// and they are fetched separately by the user process.
+func (m *Manifest) PlanWithinBudget(maxBytes int64, strategy OrderStrategy) (paths []string, totalBytes int64) {
+	for _, path := range m.OrderedPaths(strategy) {
+		entry := m.Contents[path]
+		if entry.IsReference() {
+			continue
+		}
+		if entry.Size > maxBytes-totalBytes {
+			break
+		}
+		paths = append(paths, path)
+		totalBytes += entry.Size
+	}
+	return paths, totalBytes
+}
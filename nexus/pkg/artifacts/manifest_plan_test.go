@@ -0,0 +1,100 @@
+package artifacts
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManifestDownloadPlan(t *testing.T) {
+	dir := t.TempDir()
+	present := filepath.Join(dir, "present.txt")
+	absent := filepath.Join(dir, "absent.txt")
+	assert.Nil(t, os.WriteFile(present, []byte("x"), 0600))
+	url := "https://storage.example.com/file?sig=abc"
+	ref := "s3://bucket/key"
+
+	manifest := Manifest{Contents: map[string]ManifestEntry{
+		"local.txt":  {Digest: "a", Size: 1, LocalPath: &present},
+		"remote.txt": {Digest: "b", Size: 20, DownloadURL: &url},
+		"stale.txt":  {Digest: "c", Size: 300, LocalPath: &absent, DownloadURL: &url},
+		"ref.txt":    {Digest: "d", Size: 4000, Ref: &ref},
+	}}
+
+	assert.Equal(t,
+		DownloadPlan{
+			Download:      []string{"remote.txt", "stale.txt"},
+			References:    []string{"ref.txt"},
+			Local:         []string{"local.txt"},
+			DownloadBytes: 320,
+		},
+		manifest.DownloadPlan(),
+	)
+}
+
+func TestManifestOrderedPaths(t *testing.T) {
+	manifest := Manifest{Contents: map[string]ManifestEntry{
+		"b-medium": {Digest: "1", Size: 50},
+		"a-large":  {Digest: "2", Size: 900},
+		"d-small":  {Digest: "3", Size: 1},
+		"c-medium": {Digest: "4", Size: 50},
+	}}
+
+	assert.Equal(t,
+		[]string{"a-large", "b-medium", "c-medium", "d-small"},
+		manifest.OrderedPaths(Lexical),
+	)
+	assert.Equal(t,
+		[]string{"d-small", "b-medium", "c-medium", "a-large"},
+		manifest.OrderedPaths(SmallestFirst),
+	)
+	assert.Equal(t,
+		[]string{"a-large", "b-medium", "c-medium", "d-small"},
+		manifest.OrderedPaths(LargestFirst),
+	)
+}
+
+func TestManifestPlanWithinBudget(t *testing.T) {
+	ref := "s3://bucket/ref"
+	manifest := Manifest{Contents: map[string]ManifestEntry{
+		"a.txt":   {Digest: "a", Size: 10},
+		"b.txt":   {Digest: "b", Size: 20},
+		"c.txt":   {Digest: "c", Size: 30},
+		"ref.txt": {Ref: &ref, Size: 1},
+	}}
+
+	paths, total := manifest.PlanWithinBudget(30, SmallestFirst)
+	assert.Equal(t, []string{"a.txt", "b.txt"}, paths)
+	assert.Equal(t, int64(30), total)
+
+	paths, total = manifest.PlanWithinBudget(29, SmallestFirst)
+	assert.Equal(t, []string{"a.txt"}, paths)
+	assert.Equal(t, int64(10), total)
+
+	// Selection stops at the first entry that doesn't fit.
+	paths, total = manifest.PlanWithinBudget(45, LargestFirst)
+	assert.Equal(t, []string{"c.txt"}, paths)
+	assert.Equal(t, int64(30), total)
+
+	// References are excluded even when they would fit.
+	paths, total = manifest.PlanWithinBudget(1000, Lexical)
+	assert.Equal(t, []string{"a.txt", "b.txt", "c.txt"}, paths)
+	assert.Equal(t, int64(60), total)
+
+	paths, total = manifest.PlanWithinBudget(0, SmallestFirst)
+	assert.Empty(t, paths)
+	assert.Equal(t, int64(0), total)
+}
+
+func TestManifestDownloadPlanDirectories(t *testing.T) {
+	manifest := Manifest{Contents: map[string]ManifestEntry{
+		"a.txt":  {Digest: "a", Size: 1},
+		"empty/": {Digest: emptyMD5Digest},
+	}}
+	plan := manifest.DownloadPlan()
+	assert.Equal(t, []string{"a.txt"}, plan.Download)
+	assert.Equal(t, []string{"empty/"}, plan.Directories)
+	assert.Equal(t, int64(1), plan.DownloadBytes)
+}
@@ -0,0 +1,73 @@
+package artifacts
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+// WriteIfMatch uploads m to url with a PUT request that only succeeds if the
+// stored manifest's ETag is still expectedETag, and returns the ETag of the
+// new manifest.
+//
+// This allows optimistic concurrency: read a manifest and its ETag, modify
+// it, then write it back with WriteIfMatch. If another writer got there
+// first, the server responds with 412 Precondition Failed and the returned
+// error wraps ErrManifestConflict; the caller should reload and try again.
+//
+// The request is not retried, since a failed response doesn't tell whether
+// the write was applied; the caller should reload to find out.
+func (l *ManifestLoader) WriteIfMatch(
+	ctx context.Context,
+	url string,
+	expectedETag string,
+	m *Manifest,
+) (string, error) {
+	if expectedETag == "" {
+		return "", fmt.Errorf("conditional manifest write requires an expected ETag")
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return "", fmt.Errorf("manifest json.Marshal: %w", err)
+	}
+	req, err := retryablehttp.NewRequestWithContext(ctx, http.MethodPut, url, data)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", expectedETag)
+	if err := l.authorize(req); err != nil {
+		return "", err
+	}
+	// A retry after a write that was applied but answered with an error
+	// would fail If-Match and look like a conflict, so don't retry.
+	client := l.newClient()
+	client.RetryMax = 0
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusNoContent:
+		return resp.Header.Get("ETag"), nil
+	case http.StatusPreconditionFailed:
+		return "", fmt.Errorf("%w: ETag no longer matches %s", ErrManifestConflict, expectedETag)
+	default:
+		return "", fmt.Errorf("request to put manifest to url failed with status code: %d", resp.StatusCode)
+	}
+}
+
+// WriteManifestIfMatch calls WriteIfMatch on the default loader.
+func WriteManifestIfMatch(
+	ctx context.Context,
+	url string,
+	expectedETag string,
+	m *Manifest,
+) (string, error) {
+	return defaultManifestLoader.WriteIfMatch(ctx, url, expectedETag, m)
+}
@@ -0,0 +1,90 @@
+package artifacts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// etagStore is a test server holding a single manifest that only accepts
+// PUTs whose If-Match header has the current ETag.
+type etagStore struct {
+	mu       sync.Mutex
+	etag     string
+	version  int
+	manifest Manifest
+
+	// failAfterWrite makes the next PUT respond 502 Bad Gateway after the
+	// write is applied.
+	failAfterWrite bool
+}
+
+func (s *etagStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.Method != http.MethodPut || r.Header.Get("Content-Type") != "application/json" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if r.Header.Get("If-Match") != s.etag {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(&s.manifest); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s.version++
+	s.etag = fmt.Sprintf(`"v%d"`, s.version)
+	if s.failAfterWrite {
+		s.failAfterWrite = false
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("ETag", s.etag)
+	w.WriteHeader(http.StatusOK)
+}
+
+func TestWriteManifestIfMatch(t *testing.T) {
+	store := &etagStore{etag: `"v0"`}
+	server := httptest.NewServer(store)
+	defer server.Close()
+
+	manifest := Manifest{Version: 1, Contents: map[string]ManifestEntry{"a.txt": {Digest: "a", Size: 1}}}
+	etag, err := newTestManifestLoader().WriteIfMatch(context.Background(), server.URL, `"v0"`, &manifest)
+	assert.Nil(t, err)
+	assert.Equal(t, `"v1"`, etag)
+	assert.Equal(t, "a", store.manifest.Contents["a.txt"].Digest)
+
+	// A second writer that read the manifest before the first write loses.
+	stale := Manifest{Version: 1, Contents: map[string]ManifestEntry{"b.txt": {Digest: "b", Size: 1}}}
+	_, err = newTestManifestLoader().WriteIfMatch(context.Background(), server.URL, `"v0"`, &stale)
+	assert.ErrorIs(t, err, ErrManifestConflict)
+	assert.Equal(t, "a", store.manifest.Contents["a.txt"].Digest)
+	assert.Equal(t, `"v1"`, store.etag)
+
+	_, err = newTestManifestLoader().WriteIfMatch(context.Background(), server.URL, "", &manifest)
+	assert.ErrorContains(t, err, "requires an expected ETag")
+}
+
+func TestWriteManifestIfMatchNotRetried(t *testing.T) {
+	store := &etagStore{etag: `"v0"`, failAfterWrite: true}
+	server := httptest.NewServer(store)
+	defer server.Close()
+
+	// The write is applied but the response is lost; a retry would send the
+	// old ETag and report a conflict for a write that succeeded.
+	manifest := Manifest{Version: 1, Contents: map[string]ManifestEntry{"a.txt": {Digest: "a", Size: 1}}}
+	_, err := newTestManifestLoader().WriteIfMatch(context.Background(), server.URL, `"v0"`, &manifest)
+	assert.NotNil(t, err)
+	assert.False(t, errors.Is(err, ErrManifestConflict), err)
+	assert.Equal(t, 1, store.version)
+	assert.Equal(t, "a", store.manifest.Contents["a.txt"].Digest)
+}
@@ -0,0 +1,119 @@
+package artifacts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// HTTPRange is a range of bytes to fetch with an HTTP Range request. Both
+// ends are inclusive, as in the Range header.
+type HTTPRange struct {
+	Start int64
+	End   int64
+}
+
+// Size returns the number of bytes in the range.
+func (r HTTPRange) Size() int64 {
+	return r.End - r.Start + 1
+}
+
+// Header returns the value of the Range header that requests the range.
+func (r HTTPRange) Header() string {
+	return fmt.Sprintf("bytes=%d-%d", r.Start, r.End)
+}
+
+// RangeRequests splits the entry's contents into consecutive ranges of
+// chunkSize bytes, the last of which may be shorter. An empty entry has no
+// ranges, and a non-positive chunkSize yields a single range for the whole
+// entry.
+func (e *ManifestEntry) RangeRequests(chunkSize int64) []HTTPRange {
+	if e.Size <= 0 {
+		return nil
+	}
+	if chunkSize <= 0 {
+		chunkSize = e.Size
+	}
+	ranges := make([]HTTPRange, 0, (e.Size+chunkSize-1)/chunkSize)
+	for start := int64(0); start < e.Size; start += chunkSize {
+		ranges = append(ranges, HTTPRange{Start: start, End: min(start+chunkSize, e.Size) - 1})
+	}
+	return ranges
+}
+
+// DownloadRanges fetches each of ranges from url using at most workers
+// concurrent requests, and writes each range's bytes to dst at the range's
+// offset, reassembling the file.
+//
+// It returns the errors for any ranges that failed, so that a caller can
+// retry just those ranges. If client is nil, http.DefaultClient is used.
+func DownloadRanges(
+	ctx context.Context,
+	client *http.Client,
+	url string,
+	ranges []HTTPRange,
+	dst io.WriterAt,
+	workers int,
+) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	errs := make([]error, len(ranges))
+	semaphore := make(chan struct{}, workers)
+	wg := sync.WaitGroup{}
+	for i, r := range ranges {
+		select {
+		case <-ctx.Done():
+			errs[i] = ctx.Err()
+			continue
+		case semaphore <- struct{}{}:
+		}
+		wg.Add(1)
+		go func(i int, r HTTPRange) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			if err := downloadRange(ctx, client, url, r, dst); err != nil {
+				errs[i] = fmt.Errorf("error downloading %s: %w", r.Header(), err)
+			}
+		}(i, r)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func downloadRange(
+	ctx context.Context,
+	client *http.Client,
+	url string,
+	r HTTPRange,
+	dst io.WriterAt,
+) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", r.Header())
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf("expected status code 206, got %d", resp.StatusCode)
+	}
+	n, err := io.Copy(io.NewOffsetWriter(dst, r.Start), io.LimitReader(resp.Body, r.Size()))
+	if err != nil {
+		return err
+	}
+	if n != r.Size() {
+		return fmt.Errorf("expected %d bytes, got %d", r.Size(), n)
+	}
+	return nil
+}
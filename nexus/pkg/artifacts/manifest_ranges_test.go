@@ -0,0 +1,56 @@
+package artifacts
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManifestEntryRangeRequests(t *testing.T) {
+	even := ManifestEntry{Digest: "a", Size: 30}
+	assert.Equal(t, []HTTPRange{{0, 9}, {10, 19}, {20, 29}}, even.RangeRequests(10))
+
+	remainder := ManifestEntry{Digest: "a", Size: 25}
+	ranges := remainder.RangeRequests(10)
+	assert.Equal(t, []HTTPRange{{0, 9}, {10, 19}, {20, 24}}, ranges)
+	assert.Equal(t, int64(5), ranges[2].Size())
+	assert.Equal(t, "bytes=20-24", ranges[2].Header())
+
+	assert.Equal(t, []HTTPRange{{0, 24}}, remainder.RangeRequests(100))
+	assert.Equal(t, []HTTPRange{{0, 24}}, remainder.RangeRequests(0))
+	assert.Empty(t, (&ManifestEntry{Digest: "a"}).RangeRequests(10))
+}
+
+func TestDownloadRanges(t *testing.T) {
+	contents := strings.Repeat("0123456789abcdef", 1000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "file", time.Time{}, strings.NewReader(contents))
+	}))
+	defer server.Close()
+
+	entry := ManifestEntry{Digest: "a", Size: int64(len(contents))}
+	f, err := os.Create(filepath.Join(t.TempDir(), "file"))
+	assert.Nil(t, err)
+	defer f.Close()
+
+	err = DownloadRanges(context.Background(), nil, server.URL, entry.RangeRequests(999), f, 4)
+	assert.Nil(t, err)
+	downloaded, err := os.ReadFile(f.Name())
+	assert.Nil(t, err)
+	assert.True(t, bytes.Equal([]byte(contents), downloaded))
+
+	noRanges := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(contents))
+	}))
+	defer noRanges.Close()
+	err = DownloadRanges(context.Background(), nil, noRanges.URL, entry.RangeRequests(999), f, 4)
+	assert.ErrorContains(t, err, "expected status code 206, got 200")
+}
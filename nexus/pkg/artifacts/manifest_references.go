@@ -0,0 +1,136 @@
+package artifacts
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ReferenceHandler fetches the contents of reference entries whose Ref has a
+// particular URI scheme, such as "s3" or "gs".
+type ReferenceHandler interface {
+	// Scheme returns the URI scheme the handler fetches, without "://".
+	Scheme() string
+
+	// Fetch writes the contents of the object at ref to dst.
+	Fetch(ctx context.Context, ref string, dst io.Writer) error
+}
+
+var (
+	referenceHandlersMu sync.RWMutex
+	referenceHandlers   = map[string]ReferenceHandler{}
+)
+
+// RegisterReferenceHandler makes h handle references with h.Scheme(),
+// replacing any handler previously registered for that scheme.
+func RegisterReferenceHandler(h ReferenceHandler) {
+	referenceHandlersMu.Lock()
+	defer referenceHandlersMu.Unlock()
+	referenceHandlers[h.Scheme()] = h
+}
+
+// referenceHandler returns the handler registered for scheme, if any.
+func referenceHandler(scheme string) (ReferenceHandler, bool) {
+	referenceHandlersMu.RLock()
+	defer referenceHandlersMu.RUnlock()
+	h, ok := referenceHandlers[scheme]
+	return h, ok
+}
+
+// FetchReference writes the contents of the reference entry at path to dst,
+// using the handler registered for the reference's scheme.
+func (m *Manifest) FetchReference(ctx context.Context, path string, dst io.Writer) error {
+	entry, err := m.GetManifestEntryFromArtifactFilePath(path)
+	if err != nil {
+		return err
+	}
+	scheme, err := entry.RefScheme()
+	if err != nil {
+		return fmt.Errorf("manifest entry %q: %w", path, err)
+	}
+	h, ok := referenceHandler(scheme)
+	if !ok {
+		return fmt.Errorf("no reference handler registered for scheme %q", scheme)
+	}
+	if err := h.Fetch(ctx, *entry.Ref, dst); err != nil {
+		return fmt.Errorf("error fetching reference %s: %w", *entry.Ref, err)
+	}
+	return nil
+}
+
+// ScanReferences checks that the object behind each reference entry still
+// exists and matches the manifest, and returns an error for each path that
+// does not.
+//
+// resolver looks up the object at ref and returns its size and ETag. A
+// reference fails the scan if resolver returns an error, if the size differs
+// from the entry's, or if resolver returns a non-empty ETag that differs from
+// the entry's digest. If ctx is cancelled, unscanned references map to the
+// context's error.
+func (m *Manifest) ScanReferences(
+	ctx context.Context,
+	resolver func(ctx context.Context, ref string) (size int64, etag string, err error),
+) map[string]error {
+	errs := make(map[string]error)
+	for _, path := range m.SortedPaths() {
+		entry := m.Contents[path]
+		if entry.Ref == nil {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			errs[path] = err
+			continue
+		}
+		size, etag, err := resolver(ctx, *entry.Ref)
+		switch {
+		case err != nil:
+			errs[path] = fmt.Errorf("error resolving reference %s: %w", *entry.Ref, err)
+		case size != entry.Size:
+			errs[path] = fmt.Errorf(
+				"reference %s has size %d, expected %d", *entry.Ref, size, entry.Size,
+			)
+		case etag != "" && strings.Trim(etag, `"`) != strings.Trim(entry.Digest, `"`):
+			errs[path] = fmt.Errorf(
+				"reference %s has ETag %s, expected %s", *entry.Ref, etag, entry.Digest,
+			)
+		}
+	}
+	return errs
+}
+
+// EnforceAllowedRefSchemes returns an error listing every reference entry
+// whose Ref has a scheme not in allowed, or has no valid scheme at all. This
+// lets callers refuse untrusted artifacts whose references could read local
+// files or internal services, such as "file" references.
+//
+// Schemes are compared case-insensitively, and may be given with or without
+// a trailing "://".
+func (m *Manifest) EnforceAllowedRefSchemes(allowed []string) error {
+	allowedSchemes := make(map[string]bool, len(allowed))
+	for _, scheme := range allowed {
+		allowedSchemes[strings.ToLower(strings.TrimSuffix(scheme, "://"))] = true
+	}
+
+	var disallowed []string
+	for path, entry := range m.Contents {
+		if entry.Ref == nil {
+			continue
+		}
+		scheme, err := entry.RefScheme()
+		if err == nil && allowedSchemes[strings.ToLower(scheme)] {
+			continue
+		}
+		disallowed = append(disallowed, fmt.Sprintf("%s (%s)", path, *entry.Ref))
+	}
+	if len(disallowed) == 0 {
+		return nil
+	}
+	sort.Strings(disallowed)
+	return fmt.Errorf(
+		"manifest has references with disallowed schemes: %s",
+		strings.Join(disallowed, ", "),
+	)
+}
@@ -0,0 +1,119 @@
+package artifacts
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeReferenceHandler struct {
+	scheme  string
+	fetched []string
+}
+
+func (h *fakeReferenceHandler) Scheme() string {
+	return h.scheme
+}
+
+func (h *fakeReferenceHandler) Fetch(ctx context.Context, ref string, dst io.Writer) error {
+	h.fetched = append(h.fetched, ref)
+	if ref == h.scheme+"://bucket/broken" {
+		return fmt.Errorf("object not found")
+	}
+	_, err := fmt.Fprintf(dst, "contents of %s", ref)
+	return err
+}
+
+func TestManifestFetchReference(t *testing.T) {
+	handler := &fakeReferenceHandler{scheme: "fake-fetch"}
+	RegisterReferenceHandler(handler)
+
+	ref, broken, unknown := "fake-fetch://bucket/a", "fake-fetch://bucket/broken", "unregistered://bucket/a"
+	manifest := Manifest{Contents: map[string]ManifestEntry{
+		"a.txt":       {Ref: &ref},
+		"broken.txt":  {Ref: &broken},
+		"unknown.txt": {Ref: &unknown},
+		"stored.txt":  {Digest: "x"},
+	}}
+
+	var buf bytes.Buffer
+	assert.Nil(t, manifest.FetchReference(context.Background(), "a.txt", &buf))
+	assert.Equal(t, "contents of fake-fetch://bucket/a", buf.String())
+
+	err := manifest.FetchReference(context.Background(), "broken.txt", io.Discard)
+	assert.ErrorContains(t, err, "object not found")
+	assert.Equal(t, []string{ref, broken}, handler.fetched)
+
+	err = manifest.FetchReference(context.Background(), "unknown.txt", io.Discard)
+	assert.ErrorContains(t, err, `no reference handler registered for scheme "unregistered"`)
+
+	err = manifest.FetchReference(context.Background(), "stored.txt", io.Discard)
+	assert.ErrorContains(t, err, "not a reference")
+}
+
+func TestManifestScanReferences(t *testing.T) {
+	objects := map[string]struct {
+		size int64
+		etag string
+	}{
+		"s3://bucket/ok":      {10, `"etag-ok"`},
+		"s3://bucket/resized": {11, "etag-resized"},
+		"s3://bucket/changed": {10, "etag-new"},
+		"s3://bucket/no-etag": {10, ""},
+	}
+	resolver := func(ctx context.Context, ref string) (int64, string, error) {
+		object, ok := objects[ref]
+		if !ok {
+			return 0, "", fmt.Errorf("object not found")
+		}
+		return object.size, object.etag, nil
+	}
+	newRef := func(ref, digest string) ManifestEntry {
+		return ManifestEntry{Ref: &ref, Digest: digest, Size: 10}
+	}
+	manifest := Manifest{Contents: map[string]ManifestEntry{
+		"ok.txt":      newRef("s3://bucket/ok", "etag-ok"),
+		"resized.txt": newRef("s3://bucket/resized", "etag-resized"),
+		"changed.txt": newRef("s3://bucket/changed", "etag-old"),
+		"no-etag.txt": newRef("s3://bucket/no-etag", "etag"),
+		"missing.txt": newRef("s3://bucket/missing", "etag"),
+		"stored.txt":  {Digest: "x", Size: 1},
+	}}
+
+	errs := manifest.ScanReferences(context.Background(), resolver)
+	assert.Len(t, errs, 3)
+	assert.ErrorContains(t, errs["resized.txt"], "has size 11, expected 10")
+	assert.ErrorContains(t, errs["changed.txt"], "has ETag etag-new, expected etag-old")
+	assert.ErrorContains(t, errs["missing.txt"], "object not found")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	errs = manifest.ScanReferences(ctx, resolver)
+	assert.Len(t, errs, 5)
+	assert.ErrorIs(t, errs["ok.txt"], context.Canceled)
+}
+
+func TestManifestEnforceAllowedRefSchemes(t *testing.T) {
+	s3, gs, local := "s3://bucket/a", "GS://bucket/b", "file:///etc/passwd"
+	manifest := Manifest{Contents: map[string]ManifestEntry{
+		"a.txt":      {Ref: &s3},
+		"b.txt":      {Ref: &gs},
+		"stored.txt": {Digest: "x"},
+	}}
+	assert.Nil(t, manifest.EnforceAllowedRefSchemes([]string{"s3", "gs://"}))
+
+	manifest.Contents["passwd"] = ManifestEntry{Ref: &local}
+	err := manifest.EnforceAllowedRefSchemes([]string{"s3", "gs"})
+	assert.ErrorContains(t, err, "disallowed schemes: passwd (file:///etc/passwd)")
+
+	err = manifest.EnforceAllowedRefSchemes([]string{"s3"})
+	assert.ErrorContains(t, err, "b.txt (GS://bucket/b), passwd (file:///etc/passwd)")
+
+	noScheme := "bucket/key"
+	onlyNoScheme := Manifest{Contents: map[string]ManifestEntry{"c.txt": {Ref: &noScheme}}}
+	assert.ErrorContains(t, onlyNoScheme.EnforceAllowedRefSchemes([]string{"s3"}), "c.txt (bucket/key)")
+}
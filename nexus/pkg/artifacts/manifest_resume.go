@@ -0,0 +1,119 @@
+package artifacts
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+// errRangeNotSatisfiable is returned by getWithHeader when the server
+// rejects a Range request with 416 Range Not Satisfiable.
+var errRangeNotSatisfiable = errors.New("requested range not satisfiable")
+
+// DownloadResumable downloads the manifest at url, given partial, the bytes
+// of it already downloaded by an interrupted attempt, and returns the full
+// manifest body.
+//
+// If partial is non-empty and the server advertises support for byte ranges
+// with "Accept-Ranges: bytes", only the remaining bytes are requested with a
+// Range header. Otherwise, or if the server doesn't honor the range, the
+// whole manifest is downloaded again. The manifest must not have changed
+// since partial was downloaded.
+//
+// If the download is interrupted again, the bytes read so far are returned
+// along with the error, so that they can be passed to another call.
+func (l *ManifestLoader) DownloadResumable(
+	ctx context.Context,
+	url string,
+	partial []byte,
+) ([]byte, error) {
+	if len(partial) == 0 || !l.acceptsRanges(ctx, url) {
+		return l.downloadFrom(ctx, url, nil)
+	}
+
+	header := http.Header{}
+	header.Set("Range", fmt.Sprintf("bytes=%d-", len(partial)))
+	// A range of a compressed response can't be decompressed on its own.
+	header.Set("Accept-Encoding", "identity")
+	resp, err := l.getWithHeader(ctx, url, header)
+	if errors.Is(err, errRangeNotSatisfiable) {
+		return l.downloadFrom(ctx, url, nil)
+	}
+	if err != nil {
+		return partial, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		// The server ignored the range and sent the whole manifest.
+		return readManifestBody(resp.Body, nil)
+	}
+	start, ok := contentRangeStart(resp.Header.Get("Content-Range"))
+	if !ok || start != int64(len(partial)) {
+		return l.downloadFrom(ctx, url, nil)
+	}
+	// Copy partial so that the caller's slice is never appended to.
+	return readManifestBody(resp.Body, append([]byte(nil), partial...))
+}
+
+// downloadFrom downloads the manifest at url with a plain GET and appends it
+// to prefix, returning the bytes read so far on error.
+func (l *ManifestLoader) downloadFrom(ctx context.Context, url string, prefix []byte) ([]byte, error) {
+	resp, err := l.get(ctx, url)
+	if err != nil {
+		return prefix, err
+	}
+	defer resp.Body.Close()
+	return readManifestBody(resp.Body, prefix)
+}
+
+// readManifestBody appends all of body to prefix, returning the bytes read
+// so far on error.
+func readManifestBody(body io.Reader, prefix []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(prefix)
+	if _, err := buf.ReadFrom(body); err != nil {
+		return buf.Bytes(), fmt.Errorf("error reading response body: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// acceptsRanges reports whether a HEAD request for url says that the server
+// supports byte ranges. Errors are treated as no support, and a subsequent
+// full download will report them.
+func (l *ManifestLoader) acceptsRanges(ctx context.Context, url string) bool {
+	req, err := retryablehttp.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return false
+	}
+	if err := l.authorize(req); err != nil {
+		return false
+	}
+	resp, err := l.newClient().Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK && resp.Header.Get("Accept-Ranges") == "bytes"
+}
+
+// contentRangeStart returns the first byte position of a Content-Range
+// header value such as "bytes 100-199/200".
+func contentRangeStart(contentRange string) (int64, bool) {
+	spec, ok := strings.CutPrefix(contentRange, "bytes ")
+	if !ok {
+		return 0, false
+	}
+	first, _, ok := strings.Cut(spec, "-")
+	if !ok {
+		return 0, false
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	return start, err == nil
+}
@@ -0,0 +1,94 @@
+package artifacts
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManifestLoaderDownloadResumable(t *testing.T) {
+	body := []byte(`{"version":1,"contents":{"a.txt":{"digest":"x","size":1},"b.txt":{"digest":"y","size":2}}}`)
+	var mu sync.Mutex
+	interrupt := true
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Method == http.MethodGet {
+			ranges = append(ranges, r.Header.Get("Range"))
+		}
+		if interrupt && r.Method == http.MethodGet {
+			// Promise the whole body but send only half of it.
+			interrupt = false
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			_, _ = w.Write(body[:len(body)/2])
+			return
+		}
+		http.ServeContent(w, r, "manifest.json", time.Time{}, bytes.NewReader(body))
+	}))
+	defer server.Close()
+
+	loader := newTestManifestLoader()
+	partial, err := loader.DownloadResumable(context.Background(), server.URL, nil)
+	assert.NotNil(t, err)
+	assert.Equal(t, body[:len(body)/2], partial)
+
+	full, err := loader.DownloadResumable(context.Background(), server.URL, partial)
+	assert.Nil(t, err)
+	assert.Equal(t, body, full)
+	assert.Equal(t, []string{"", "bytes=" + strconv.Itoa(len(partial)) + "-"}, ranges)
+	manifest, err := parseManifest(full)
+	assert.Nil(t, err)
+	assert.Len(t, manifest.Contents, 2)
+}
+
+func TestManifestLoaderDownloadResumableWithoutRangeSupport(t *testing.T) {
+	body := []byte(`{"version":1,"contents":{}}`)
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			ranges = append(ranges, r.Header.Get("Range"))
+			_, _ = w.Write(body)
+		}
+	}))
+	defer server.Close()
+
+	partial := body[:5]
+	full, err := newTestManifestLoader().DownloadResumable(context.Background(), server.URL, partial)
+	assert.Nil(t, err)
+	assert.Equal(t, body, full)
+	assert.Equal(t, []string{""}, ranges, "no Range request without Accept-Ranges")
+}
+
+func TestManifestLoaderDownloadResumableIgnoredRange(t *testing.T) {
+	body := []byte(`{"version":1,"contents":{}}`)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Advertise ranges but always send the whole body.
+		w.Header().Set("Accept-Ranges", "bytes")
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
+
+	partial := append([]byte(nil), body[:5]...)
+	full, err := newTestManifestLoader().DownloadResumable(context.Background(), server.URL, partial)
+	assert.Nil(t, err)
+	assert.Equal(t, body, full)
+	assert.Equal(t, body[:5], partial)
+}
+
+func TestContentRangeStart(t *testing.T) {
+	start, ok := contentRangeStart("bytes 100-199/200")
+	assert.True(t, ok)
+	assert.Equal(t, int64(100), start)
+	for _, invalid := range []string{"", "bytes */200", "items 1-2/3", "bytes x-1/2"} {
+		_, ok := contentRangeStart(invalid)
+		assert.False(t, ok, invalid)
+	}
+}
@@ -0,0 +1,82 @@
+package artifacts
+
+import (
+	"context"
+	"fmt"
+)
+
+// SelfCheckOptions configures Manifest.SelfCheck.
+type SelfCheckOptions struct {
+	// VerifyLocalFiles checks that every stored file entry has a LocalPath
+	// whose contents match the entry's digest. Directory placeholders and
+	// symlinks are skipped.
+	VerifyLocalFiles bool
+
+	// ReferenceResolver, if set, is used to check that every reference
+	// entry's object exists and matches. See Manifest.ScanReferences.
+	ReferenceResolver func(ctx context.Context, ref string) (size int64, etag string, err error)
+}
+
+// SelfCheckReport holds the results of Manifest.SelfCheck, by category. A
+// nil or empty field means that category passed or was not checked.
+type SelfCheckReport struct {
+	// Schema is the result of Manifest.ValidateSchema.
+	Schema error
+
+	// Manifest is the result of Manifest.Validate.
+	Manifest error
+
+	// Entries is the result of Manifest.ValidateEntries.
+	Entries []error
+
+	// LocalFiles maps the paths of stored entries that failed local
+	// verification to the reason.
+	LocalFiles map[string]error
+
+	// References maps the paths of reference entries that failed the
+	// reference scan to the reason.
+	References map[string]error
+}
+
+// OK reports whether every check passed.
+func (r SelfCheckReport) OK() bool {
+	return r.Schema == nil && r.Manifest == nil && len(r.Entries) == 0 &&
+		len(r.LocalFiles) == 0 && len(r.References) == 0
+}
+
+// SelfCheck runs every available check on the manifest: schema, manifest and
+// entry validation, and, as configured by opts, local file verification and
+// a reference scan.
+//
+// If ctx is cancelled, the entries not yet verified report the context's
+// error.
+func (m *Manifest) SelfCheck(ctx context.Context, opts SelfCheckOptions) SelfCheckReport {
+	report := SelfCheckReport{
+		Schema:     m.ValidateSchema(),
+		Manifest:   m.Validate(),
+		Entries:    m.ValidateEntries(),
+		LocalFiles: make(map[string]error),
+		References: make(map[string]error),
+	}
+
+	if opts.VerifyLocalFiles {
+		for _, path := range m.SortedPaths() {
+			entry := m.Contents[path]
+			// Directory placeholders and symlinks have no file contents.
+			if entry.IsReference() || entry.IsDir(path) || entry.IsSymlink() {
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				report.LocalFiles[path] = err
+				continue
+			}
+			if err := entry.VerifyLocalFile(); err != nil {
+				report.LocalFiles[path] = fmt.Errorf("manifest entry %q: %w", path, err)
+			}
+		}
+	}
+	if opts.ReferenceResolver != nil {
+		report.References = m.ScanReferences(ctx, opts.ReferenceResolver)
+	}
+	return report
+}
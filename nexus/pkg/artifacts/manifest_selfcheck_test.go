@@ -0,0 +1,81 @@
+package artifacts
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newSelfCheckTestManifest(t *testing.T) Manifest {
+	path := filepath.Join(t.TempDir(), "a.txt")
+	assert.Nil(t, os.WriteFile(path, []byte("contents"), 0600))
+	entry, err := EntryFromLocalFile(path)
+	assert.Nil(t, err)
+
+	ref := "s3://bucket/b.txt"
+	return Manifest{
+		Version:       1,
+		StoragePolicy: DefaultStoragePolicy,
+		Contents: map[string]ManifestEntry{
+			"a.txt": entry,
+			"b.txt": {Ref: &ref, Digest: "etag", Size: 5},
+		},
+	}
+}
+
+func selfCheckTestResolver(ctx context.Context, ref string) (int64, string, error) {
+	return 5, "etag", nil
+}
+
+func TestManifestSelfCheckClean(t *testing.T) {
+	manifest := newSelfCheckTestManifest(t)
+	report := manifest.SelfCheck(context.Background(), SelfCheckOptions{
+		VerifyLocalFiles:  true,
+		ReferenceResolver: selfCheckTestResolver,
+	})
+	assert.True(t, report.OK(), "%+v", report)
+	// OK can be called on the returned value directly.
+	assert.True(t, manifest.SelfCheck(context.Background(), SelfCheckOptions{}).OK())
+}
+
+func TestManifestSelfCheckSkipsDirsAndSymlinks(t *testing.T) {
+	manifest := newSelfCheckTestManifest(t)
+	target := "a.txt"
+	manifest.Contents["dir/"] = ManifestEntry{Digest: emptyMD5Digest}
+	manifest.Contents["link.txt"] = ManifestEntry{Digest: "abc", SymlinkTarget: &target}
+
+	report := manifest.SelfCheck(context.Background(), SelfCheckOptions{VerifyLocalFiles: true})
+	assert.Empty(t, report.LocalFiles)
+}
+
+func TestManifestSelfCheckCorruptedLocalFile(t *testing.T) {
+	manifest := newSelfCheckTestManifest(t)
+	assert.Nil(t, os.WriteFile(*manifest.Contents["a.txt"].LocalPath, []byte("corrupted"), 0600))
+
+	// Local files are only read when asked to.
+	report := manifest.SelfCheck(context.Background(), SelfCheckOptions{})
+	assert.True(t, report.OK())
+
+	report = manifest.SelfCheck(context.Background(), SelfCheckOptions{
+		VerifyLocalFiles:  true,
+		ReferenceResolver: selfCheckTestResolver,
+	})
+	assert.False(t, report.OK())
+	assert.Len(t, report.LocalFiles, 1)
+	assert.ErrorIs(t, report.LocalFiles["a.txt"], ErrDigestMismatch)
+	assert.Nil(t, report.Schema)
+	assert.Nil(t, report.Manifest)
+	assert.Empty(t, report.Entries)
+	assert.Empty(t, report.References)
+}
+
+func TestManifestSelfCheckInvalidManifest(t *testing.T) {
+	manifest := Manifest{Contents: map[string]ManifestEntry{"a.txt": {Size: -1}}}
+	report := manifest.SelfCheck(context.Background(), SelfCheckOptions{})
+	assert.False(t, report.OK())
+	assert.ErrorContains(t, report.Schema, `missing required field "version"`)
+	assert.Len(t, report.Entries, 1)
+}
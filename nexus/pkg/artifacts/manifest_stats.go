@@ -0,0 +1,124 @@
+package artifacts
+
+import (
+	"math"
+	"path/filepath"
+	"strings"
+)
+
+// Len returns the number of entries in the manifest.
+func (m *Manifest) Len() int {
+	return len(m.Contents)
+}
+
+// IsEmpty reports whether the manifest has no entries.
+func (m *Manifest) IsEmpty() bool {
+	return len(m.Contents) == 0
+}
+
+// CountReferences returns the number of reference entries in the manifest.
+func (m *Manifest) CountReferences() int {
+	count := 0
+	for _, entry := range m.Contents {
+		if entry.IsReference() {
+			count++
+		}
+	}
+	return count
+}
+
+// TotalSize returns the sum of the sizes of all entries in the manifest,
+// including references.
+//
+// The sum saturates at math.MaxInt64 (about 9.2 exabytes), which is far
+// beyond the size of any real artifact.
+func (m *Manifest) TotalSize() int64 {
+	var total int64
+	for _, entry := range m.Contents {
+		total = addSizes(total, entry.Size)
+	}
+	return total
+}
+
+// SizeExcludingReferences is like TotalSize, but skips reference entries,
+// whose bytes are not stored by W&B.
+func (m *Manifest) SizeExcludingReferences() int64 {
+	var total int64
+	for _, entry := range m.Contents {
+		if entry.Ref != nil {
+			continue
+		}
+		total = addSizes(total, entry.Size)
+	}
+	return total
+}
+
+// addSizes adds two non-negative sizes, saturating instead of overflowing.
+func addSizes(a, b int64) int64 {
+	if b > 0 && a > math.MaxInt64-b {
+		return math.MaxInt64
+	}
+	return a + b
+}
+
+// DedupStats summarizes how much storage content-addressing saves for an
+// artifact.
+type DedupStats struct {
+	// UniqueDigests is the number of distinct digests among stored entries.
+	UniqueDigests int
+
+	// LogicalBytes is the total size of all stored entries.
+	LogicalBytes int64
+
+	// PhysicalBytes is the total size of one copy of each distinct digest.
+	PhysicalBytes int64
+}
+
+// SavedBytes is the number of bytes that deduplication avoids storing.
+func (s DedupStats) SavedBytes() int64 {
+	return s.LogicalBytes - s.PhysicalBytes
+}
+
+// DedupStats computes deduplication statistics over the manifest's stored
+// entries. References are skipped, since they are not kept in W&B storage.
+func (m *Manifest) DedupStats() DedupStats {
+	var stats DedupStats
+	seen := make(map[string]bool)
+	for _, entry := range m.Contents {
+		if entry.Ref != nil {
+			continue
+		}
+		stats.LogicalBytes = addSizes(stats.LogicalBytes, entry.Size)
+		if seen[entry.Digest] {
+			continue
+		}
+		seen[entry.Digest] = true
+		stats.PhysicalBytes = addSizes(stats.PhysicalBytes, entry.Size)
+	}
+	stats.UniqueDigests = len(seen)
+	return stats
+}
+
+// ExtStat summarizes the entries with a given file extension.
+type ExtStat struct {
+	// Count is the number of entries.
+	Count int
+
+	// TotalSize is the sum of the entries' sizes.
+	TotalSize int64
+}
+
+// ExtensionStats counts the manifest's entries, and sums their sizes, by
+// lowercased file extension, such as ".png". Entries whose paths have no
+// extension are counted under "". References are included.
+func (m *Manifest) ExtensionStats() map[string]ExtStat {
+	stats := make(map[string]ExtStat)
+	for path, entry := range m.Contents {
+		ext := strings.ToLower(filepath.Ext(path))
+		stat := stats[ext]
+		stat.Count++
+		stat.TotalSize = addSizes(stat.TotalSize, entry.Size)
+		stats[ext] = stat
+	}
+	return stats
+}
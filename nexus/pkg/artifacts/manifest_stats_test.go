@@ -0,0 +1,96 @@
+package artifacts
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManifestTotalSize(t *testing.T) {
+	ref := "s3://bucket/key"
+	empty := Manifest{}
+	assert.Equal(t, int64(0), empty.TotalSize())
+	assert.Equal(t, int64(0), empty.SizeExcludingReferences())
+
+	references := Manifest{Contents: map[string]ManifestEntry{
+		"a": {Ref: &ref, Size: 100},
+		"b": {Ref: &ref, Size: 200},
+	}}
+	assert.Equal(t, int64(300), references.TotalSize())
+	assert.Equal(t, int64(0), references.SizeExcludingReferences())
+
+	mixed := Manifest{Contents: map[string]ManifestEntry{
+		"a": {Ref: &ref, Size: 100},
+		"b": {Digest: "b", Size: 20},
+		"c": {Digest: "c", Size: 3},
+	}}
+	assert.Equal(t, int64(123), mixed.TotalSize())
+	assert.Equal(t, int64(23), mixed.SizeExcludingReferences())
+}
+
+func TestManifestTotalSizeSaturates(t *testing.T) {
+	huge := Manifest{Contents: map[string]ManifestEntry{
+		"a": {Digest: "a", Size: math.MaxInt64},
+		"b": {Digest: "b", Size: 1},
+	}}
+	assert.Equal(t, int64(math.MaxInt64), huge.TotalSize())
+}
+
+func TestManifestDedupStats(t *testing.T) {
+	ref := "s3://bucket/key"
+	manifest := Manifest{Contents: map[string]ManifestEntry{
+		"a/copy1.bin": {Digest: "shared", Size: 100},
+		"b/copy2.bin": {Digest: "shared", Size: 100},
+		"c/copy3.bin": {Digest: "shared", Size: 100},
+		"unique.txt":  {Digest: "unique", Size: 7},
+		"ref.bin":     {Digest: "shared", Ref: &ref, Size: 100},
+	}}
+
+	stats := manifest.DedupStats()
+	assert.Equal(t, DedupStats{UniqueDigests: 2, LogicalBytes: 307, PhysicalBytes: 107}, stats)
+	assert.Equal(t, int64(200), stats.SavedBytes())
+
+	empty := Manifest{}
+	assert.Equal(t, DedupStats{}, empty.DedupStats())
+}
+
+func TestManifestCounts(t *testing.T) {
+	ref := "s3://bucket/key"
+	empty := Manifest{}
+	assert.Equal(t, 0, empty.Len())
+	assert.True(t, empty.IsEmpty())
+	assert.Equal(t, 0, empty.CountReferences())
+
+	mixed := Manifest{Contents: map[string]ManifestEntry{
+		"a": {Ref: &ref, Size: 100},
+		"b": {Ref: &ref, Size: 200},
+		"c": {Digest: "c", Size: 3},
+	}}
+	assert.Equal(t, 3, mixed.Len())
+	assert.False(t, mixed.IsEmpty())
+	assert.Equal(t, 2, mixed.CountReferences())
+}
+
+func TestManifestExtensionStats(t *testing.T) {
+	ref := "s3://bucket/data.json"
+	manifest := Manifest{Contents: map[string]ManifestEntry{
+		"images/cat.png":   {Digest: "a", Size: 10},
+		"images/dog.PNG":   {Digest: "b", Size: 20},
+		"config.json":      {Digest: "c", Size: 3},
+		"remote/data.json": {Digest: "d", Ref: &ref, Size: 4},
+		"Makefile":         {Digest: "e", Size: 5},
+		"archive.tar.gz":   {Digest: "f", Size: 6},
+		"bin/tool":         {Digest: "g", Size: 7},
+	}}
+	assert.Equal(t,
+		map[string]ExtStat{
+			".png":  {Count: 2, TotalSize: 30},
+			".json": {Count: 2, TotalSize: 7},
+			".gz":   {Count: 1, TotalSize: 6},
+			"":      {Count: 2, TotalSize: 12},
+		},
+		manifest.ExtensionStats(),
+	)
+	assert.Empty(t, (&Manifest{}).ExtensionStats())
+}
@@ -0,0 +1,88 @@
+package artifacts
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/wandb/wandb/nexus/pkg/utils"
+)
+
+// StorageKey returns the key under which the entry's contents are stored,
+// relative to the entity's storage root.
+//
+// This mirrors the Python SDK's WandbStoragePolicy: in the V1 layout files
+// are addressed by digest alone, so identical files are shared across
+// artifacts; in the V2 layout they are additionally scoped to the artifact
+// that first uploaded them. Manifests with no layout are treated as V1, as in
+// the Python SDK.
+func (m *Manifest) StorageKey(entry ManifestEntry) (string, error) {
+	if entry.Ref != nil {
+		return "", fmt.Errorf("reference entries are not stored by W&B")
+	}
+	if entry.Digest == "" {
+		return "", fmt.Errorf("manifest entry has no digest")
+	}
+	md5Hex, err := utils.B64ToHex(entry.Digest)
+	if err != nil {
+		return "", fmt.Errorf("invalid digest %q: %w", entry.Digest, err)
+	}
+
+	switch layout := m.StoragePolicyConfig.StorageLayout; layout {
+	case "", StorageLayoutV1:
+		return md5Hex, nil
+	case StorageLayoutV2:
+		if entry.BirthArtifactID == nil {
+			return "", fmt.Errorf("manifest entry has no birth artifact ID")
+		}
+		return quotePathSegment(*entry.BirthArtifactID) + "/" + md5Hex, nil
+	default:
+		return "", fmt.Errorf("unsupported storage layout %q", layout)
+	}
+}
+
+// quotePathSegment percent-encodes s the way Python's urllib.parse.quote
+// does by default, so keys match the ones the Python SDK computes.
+func quotePathSegment(s string) string {
+	const hexDigits = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9',
+			c == '_', c == '.', c == '-', c == '~', c == '/':
+			b.WriteByte(c)
+		default:
+			b.WriteByte('%')
+			b.WriteByte(hexDigits[c>>4])
+			b.WriteByte(hexDigits[c&0xf])
+		}
+	}
+	return b.String()
+}
+
+// EntriesNeedingRelayout returns the sorted paths of the stored entries whose
+// storage key under targetLayout differs from their key under the manifest's
+// current layout, and which must therefore be re-keyed when the artifact is
+// migrated to targetLayout.
+//
+// Entries whose key under either layout can't be computed, such as entries
+// with no birth artifact ID in a V2 manifest, are included, since they can't
+// be stored correctly as they are. Reference entries are never included:
+// they are not stored by W&B.
+func (m *Manifest) EntriesNeedingRelayout(targetLayout string) []string {
+	target := Manifest{StoragePolicyConfig: StoragePolicyConfig{StorageLayout: targetLayout}}
+	var paths []string
+	for path, entry := range m.Contents {
+		if entry.Ref != nil {
+			continue
+		}
+		currentKey, currentErr := m.StorageKey(entry)
+		targetKey, targetErr := target.StorageKey(entry)
+		if targetErr != nil || currentErr != nil || currentKey != targetKey {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
@@ -0,0 +1,67 @@
+package artifacts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManifestStorageKey(t *testing.T) {
+	// base64 MD5 of "contents".
+	const digest = "mL99jBV4Two9YyBEQeHiqg=="
+	const md5Hex = "98bf7d8c15784f0a3d63204441e1e2aa"
+	birthID := "QXJ0aWZhY3Q6MTIz+/=="
+	entry := ManifestEntry{Digest: digest, BirthArtifactID: &birthID}
+
+	v1 := Manifest{StoragePolicyConfig: StoragePolicyConfig{StorageLayout: StorageLayoutV1}}
+	key, err := v1.StorageKey(entry)
+	assert.Nil(t, err)
+	assert.Equal(t, md5Hex, key)
+
+	v2 := Manifest{StoragePolicyConfig: StoragePolicyConfig{StorageLayout: StorageLayoutV2}}
+	key, err = v2.StorageKey(entry)
+	assert.Nil(t, err)
+	assert.Equal(t, "QXJ0aWZhY3Q6MTIz%2B/%3D%3D/"+md5Hex, key)
+
+	_, err = v2.StorageKey(ManifestEntry{Digest: digest})
+	assert.ErrorContains(t, err, "no birth artifact ID")
+	_, err = v1.StorageKey(ManifestEntry{})
+	assert.ErrorContains(t, err, "no digest")
+	ref := "s3://bucket/key"
+	_, err = v1.StorageKey(ManifestEntry{Digest: digest, Ref: &ref})
+	assert.ErrorContains(t, err, "reference entries")
+}
+
+func TestManifestEntriesNeedingRelayout(t *testing.T) {
+	// base64 MD5s of "contents" and "other".
+	const digest, otherDigest = "mL99jBV4Two9YyBEQeHiqg==", "eV3BT7gTjEt7x9SfW4/J6Q=="
+	birthID := "QXJ0aWZhY3Q6MTIz"
+	ref := "s3://bucket/key"
+	manifest := Manifest{
+		StoragePolicyConfig: StoragePolicyConfig{StorageLayout: StorageLayoutV1},
+		Contents: map[string]ManifestEntry{
+			"b.txt":       {Digest: digest, BirthArtifactID: &birthID},
+			"a.txt":       {Digest: otherDigest, BirthArtifactID: &birthID},
+			"orphan.txt":  {Digest: digest},
+			"ref.txt":     {Digest: "etag", Ref: &ref},
+			"ref-too.txt": {Ref: &ref},
+		},
+	}
+
+	assert.Equal(t,
+		[]string{"a.txt", "b.txt", "orphan.txt"},
+		manifest.EntriesNeedingRelayout(StorageLayoutV2),
+	)
+	assert.Empty(t, manifest.EntriesNeedingRelayout(StorageLayoutV1))
+	// Manifests with no layout already use V1 keys.
+	manifest.StoragePolicyConfig.StorageLayout = ""
+	assert.Empty(t, manifest.EntriesNeedingRelayout(StorageLayoutV1))
+
+	v2 := Manifest{
+		StoragePolicyConfig: StoragePolicyConfig{StorageLayout: StorageLayoutV2},
+		Contents:            manifest.Contents,
+	}
+	// orphan.txt has no valid V2 key, so it needs relayout in either direction.
+	assert.Equal(t, []string{"a.txt", "b.txt", "orphan.txt"}, v2.EntriesNeedingRelayout(StorageLayoutV1))
+	assert.Equal(t, []string{"orphan.txt"}, v2.EntriesNeedingRelayout(StorageLayoutV2))
+}
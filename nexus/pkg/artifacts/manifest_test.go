@@ -0,0 +1,530 @@
+package artifacts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wandb/wandb/nexus/pkg/service"
+	"github.com/wandb/wandb/nexus/pkg/utils"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestManifestValidateVersion(t *testing.T) {
+	manifest := Manifest{Version: SupportedManifestVersion}
+	assert.Nil(t, manifest.Validate())
+
+	manifest.Version = SupportedManifestVersion + 1
+	err := manifest.Validate()
+	assert.ErrorContains(t, err, "unsupported manifest version 2")
+	assert.ErrorContains(t, err, "max supported version is 1")
+	assert.ErrorIs(t, err, ErrManifestVersionUnsupported)
+}
+
+func TestNewManifestFromProtoRejectsNewerVersion(t *testing.T) {
+	_, err := NewManifestFromProto(&service.ArtifactManifest{
+		Version:       SupportedManifestVersion + 1,
+		StoragePolicy: "wandb-storage-policy-v1",
+	})
+	assert.ErrorIs(t, err, ErrManifestVersionUnsupported)
+}
+
+func TestManifestValidateEntries(t *testing.T) {
+	manifest := Manifest{
+		Contents: map[string]ManifestEntry{
+			"ok.txt":       {Digest: "abc", Size: 1},
+			"b-no-digest":  {Size: 1},
+			"a-negative":   {Digest: "abc", Size: -5},
+			"also-ok.json": {Digest: "def"},
+		},
+	}
+	errs := manifest.ValidateEntries()
+	assert.Len(t, errs, 2)
+	assert.ErrorContains(t, errs[0], `"a-negative"`)
+	assert.ErrorContains(t, errs[1], `"b-no-digest"`)
+}
+
+func TestNewManifestFromProtoStorageLayout(t *testing.T) {
+	manifest, err := NewManifestFromProto(&service.ArtifactManifest{Version: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, StorageLayoutV2, manifest.StoragePolicyConfig.StorageLayout)
+
+	_, err = NewManifestFromProto(&service.ArtifactManifest{
+		Version: 1,
+		StoragePolicyConfig: []*service.StoragePolicyConfigItem{
+			{Key: "storageLayout", ValueJson: `"V3"`},
+		},
+	})
+	assert.ErrorContains(t, err, "unsupported storage layout")
+}
+
+func TestV1StorageLayoutPreservedThroughWriteToFile(t *testing.T) {
+	manifest, err := NewManifestFromProto(&service.ArtifactManifest{
+		Version:       1,
+		StoragePolicy: "wandb-storage-policy-v1",
+		StoragePolicyConfig: []*service.StoragePolicyConfigItem{
+			{Key: "storageLayout", ValueJson: `"V1"`},
+		},
+		Contents: []*service.ArtifactManifestEntry{
+			{Path: "a.txt", Digest: "abc", Size: 3},
+		},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, StorageLayoutV1, manifest.StoragePolicyConfig.StorageLayout)
+
+	filename, _, err := manifest.WriteToFile()
+	assert.Nil(t, err)
+	defer os.Remove(filename)
+
+	data, err := os.ReadFile(filename)
+	assert.Nil(t, err)
+	var written Manifest
+	assert.Nil(t, json.Unmarshal(data, &written))
+	assert.Equal(t, StorageLayoutV1, written.StoragePolicyConfig.StorageLayout)
+}
+
+// failingTempFile is a real temporary file whose writes always fail.
+type failingTempFile struct {
+	*os.File
+}
+
+func (f failingTempFile) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestWriteToFileRemovesTempFileOnWriteFailure(t *testing.T) {
+	var created string
+	originalCreateTempFile := createTempFile
+	createTempFile = func(dir, pattern string) (tempFile, error) {
+		f, err := os.CreateTemp(dir, pattern)
+		if err != nil {
+			return nil, err
+		}
+		created = f.Name()
+		return failingTempFile{f}, nil
+	}
+	defer func() { createTempFile = originalCreateTempFile }()
+
+	manifest := Manifest{Version: 1, Contents: map[string]ManifestEntry{}}
+	filename, digest, err := manifest.WriteToFile()
+	assert.ErrorContains(t, err, "disk full")
+	assert.Empty(t, filename)
+	assert.Empty(t, digest)
+	assert.NotEmpty(t, created)
+	_, err = os.Stat(created)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestWriteToFileInDir(t *testing.T) {
+	dir := t.TempDir()
+	manifest := Manifest{Version: 1, Contents: map[string]ManifestEntry{}}
+	filename, digest, err := manifest.WriteToFileInDir(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, dir, filepath.Dir(filename))
+	assert.NotEmpty(t, digest)
+}
+
+func TestWriteToFileMatchesMarshaledDigest(t *testing.T) {
+	for _, numEntries := range []int{0, 1, 10, 10_000} {
+		manifest := Manifest{
+			Version:             1,
+			StoragePolicy:       "wandb-storage-policy-v1",
+			StoragePolicyConfig: StoragePolicyConfig{StorageLayout: StorageLayoutV2},
+			Contents:            map[string]ManifestEntry{},
+		}
+		for i := 0; i < numEntries; i++ {
+			manifest.Contents[fmt.Sprintf("dir/<file>&%d", i)] = ManifestEntry{
+				Digest: fmt.Sprintf("digest-%d", i),
+				Size:   int64(i),
+				Extra:  map[string]interface{}{"n": i},
+			}
+		}
+
+		data, err := json.Marshal(&manifest)
+		assert.Nil(t, err)
+		wantDigest, err := utils.ComputeB64MD5(data)
+		assert.Nil(t, err)
+
+		filename, digest, err := manifest.WriteToFileInDir(t.TempDir())
+		assert.Nil(t, err)
+		assert.Equal(t, wantDigest, digest)
+		written, err := os.ReadFile(filename)
+		assert.Nil(t, err)
+		assert.Equal(t, data, written)
+	}
+}
+
+func TestManifestProtoRoundTrip(t *testing.T) {
+	original := &service.ArtifactManifest{
+		Version:       1,
+		StoragePolicy: "wandb-storage-policy-v1",
+		StoragePolicyConfig: []*service.StoragePolicyConfigItem{
+			{Key: "storageLayout", ValueJson: `"V1"`},
+		},
+		Contents: []*service.ArtifactManifestEntry{
+			{
+				Path:            "a.txt",
+				Digest:          "abc",
+				Size:            3,
+				BirthArtifactId: "birth-id",
+				LocalPath:       "/tmp/a.txt",
+				Extra: []*service.ExtraItem{
+					{Key: "etag", ValueJson: `"xyz"`},
+					{Key: "versionID", ValueJson: `7`},
+				},
+			},
+			{
+				Path: "b.txt",
+				Ref:  "s3://bucket/b.txt",
+				Size: 5,
+			},
+		},
+	}
+
+	manifest, err := NewManifestFromProto(original)
+	assert.Nil(t, err)
+	assert.Nil(t, manifest.Contents["b.txt"].BirthArtifactID)
+	assert.Nil(t, manifest.Contents["b.txt"].LocalPath)
+	assert.Nil(t, manifest.Contents["a.txt"].Ref)
+
+	roundTripped, err := manifest.ToProto()
+	assert.Nil(t, err)
+	assert.True(t, proto.Equal(original, roundTripped), "got %v", roundTripped)
+}
+
+func TestValidateEntriesConcurrentBoundsWorkers(t *testing.T) {
+	manifest := Manifest{Contents: map[string]ManifestEntry{}}
+	for i := 0; i < 50; i++ {
+		manifest.Contents[fmt.Sprintf("file-%02d", i)] = ManifestEntry{Digest: "abc"}
+	}
+
+	const workers = 4
+	var inFlight, maxInFlight int32
+	err := manifest.ValidateEntriesConcurrent(context.Background(), workers,
+		func(ctx context.Context, path string, e ManifestEntry) error {
+			n := atomic.AddInt32(&inFlight, 1)
+			defer atomic.AddInt32(&inFlight, -1)
+			for {
+				max := atomic.LoadInt32(&maxInFlight)
+				if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			if path == "file-07" || path == "file-03" {
+				return errors.New("bad entry")
+			}
+			return nil
+		},
+	)
+	assert.LessOrEqual(t, maxInFlight, int32(workers))
+	assert.ErrorContains(t, err, `"file-03": bad entry`)
+	assert.ErrorContains(t, err, `"file-07": bad entry`)
+}
+
+func TestValidateEntriesConcurrentCancellation(t *testing.T) {
+	manifest := Manifest{Contents: map[string]ManifestEntry{}}
+	for i := 0; i < 100; i++ {
+		manifest.Contents[fmt.Sprintf("file-%02d", i)] = ManifestEntry{Digest: "abc"}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var checked int32
+	err := manifest.ValidateEntriesConcurrent(ctx, 2,
+		func(ctx context.Context, path string, e ManifestEntry) error {
+			if atomic.AddInt32(&checked, 1) == 2 {
+				cancel()
+			}
+			<-ctx.Done()
+			return nil
+		},
+	)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, atomic.LoadInt32(&checked), int32(100))
+}
+
+func TestManifestSortedIteration(t *testing.T) {
+	manifest := Manifest{Contents: map[string]ManifestEntry{
+		"b/2.txt": {Digest: "3"},
+		"a.txt":   {Digest: "1"},
+		"b/1.txt": {Digest: "2"},
+		"c":       {Digest: "4"},
+	}}
+	assert.Equal(t, []string{"a.txt", "b/1.txt", "b/2.txt", "c"}, manifest.SortedPaths())
+
+	var visited []string
+	errStop := errors.New("stop")
+	err := manifest.ForEachSorted(func(path string, e ManifestEntry) error {
+		visited = append(visited, path+"="+e.Digest)
+		if path == "b/1.txt" {
+			return errStop
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, errStop)
+	assert.Equal(t, []string{"a.txt=1", "b/1.txt=2"}, visited)
+}
+
+func TestManifestValidateSchema(t *testing.T) {
+	valid := Manifest{
+		Version:       1,
+		StoragePolicy: "wandb-storage-policy-v1",
+		Contents:      map[string]ManifestEntry{},
+	}
+	assert.Nil(t, valid.ValidateSchema())
+
+	noVersion := valid
+	noVersion.Version = 0
+	assert.ErrorContains(t, noVersion.ValidateSchema(), `"version"`)
+
+	noPolicy := valid
+	noPolicy.StoragePolicy = ""
+	assert.ErrorContains(t, noPolicy.ValidateSchema(), `"storagePolicy"`)
+
+	noContents := valid
+	noContents.Contents = nil
+	assert.ErrorContains(t, noContents.ValidateSchema(), `"contents"`)
+
+	var empty Manifest
+	err := empty.ValidateSchema()
+	assert.ErrorContains(t, err, `"version"`)
+	assert.ErrorContains(t, err, `"storagePolicy"`)
+	assert.ErrorContains(t, err, `"contents"`)
+}
+
+func TestManifestCompact(t *testing.T) {
+	blob := strings.Repeat("provider metadata ", 100)
+	manifest := Manifest{Contents: map[string]ManifestEntry{
+		"a.txt": {Digest: "a", Extra: map[string]interface{}{"etag": "e1", "blob": blob}},
+		"b.txt": {Digest: "b", Extra: map[string]interface{}{"blob": blob}},
+		"c.txt": {Digest: "c"},
+	}}
+	before, err := json.Marshal(&manifest)
+	assert.Nil(t, err)
+
+	manifest.Compact([]string{"etag"})
+	after, err := json.Marshal(&manifest)
+	assert.Nil(t, err)
+
+	assert.Less(t, len(after), len(before)/10)
+	assert.Equal(t, map[string]interface{}{"etag": "e1"}, manifest.Contents["a.txt"].Extra)
+	assert.Nil(t, manifest.Contents["b.txt"].Extra)
+	assert.Nil(t, manifest.Contents["c.txt"].Extra)
+}
+
+func TestManifestSymlinkEntries(t *testing.T) {
+	target := "../data/real.txt"
+	original := &service.ArtifactManifest{
+		Version:       1,
+		StoragePolicy: "wandb-storage-policy-v1",
+		StoragePolicyConfig: []*service.StoragePolicyConfigItem{
+			{Key: "storageLayout", ValueJson: `"V2"`},
+		},
+		Contents: []*service.ArtifactManifestEntry{
+			{
+				Path:   "link.txt",
+				Digest: "abc",
+				Size:   3,
+				Extra: []*service.ExtraItem{
+					{Key: "etag", ValueJson: `"xyz"`},
+					{Key: protoExtraSymlinkTarget, ValueJson: `"../data/real.txt"`},
+				},
+			},
+			{Path: "regular.txt", Digest: "def", Size: 4},
+		},
+	}
+
+	manifest, err := NewManifestFromProto(original)
+	assert.Nil(t, err)
+	link := manifest.Contents["link.txt"]
+	assert.True(t, link.IsSymlink())
+	assert.Equal(t, &target, link.SymlinkTarget)
+	assert.Equal(t, map[string]interface{}{"etag": "xyz"}, link.Extra)
+	regular := manifest.Contents["regular.txt"]
+	assert.False(t, regular.IsSymlink())
+
+	roundTripped, err := manifest.ToProto()
+	assert.Nil(t, err)
+	assert.True(t, proto.Equal(original, roundTripped), "got %v", roundTripped)
+
+	data, err := json.Marshal(&manifest)
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `"symlinkTarget":"../data/real.txt"`)
+	assert.Equal(t, 1, strings.Count(string(data), "symlinkTarget"))
+}
+
+func TestManifestCheckBirthArtifacts(t *testing.T) {
+	present, missing := "artifact-1", "artifact-2"
+	manifest := Manifest{Contents: map[string]ManifestEntry{
+		"valid.txt":    {Digest: "a", BirthArtifactID: &present},
+		"dangling.txt": {Digest: "b", BirthArtifactID: &missing},
+		"unborn.txt":   {Digest: "c"},
+	}}
+
+	assert.Equal(t, []string{"dangling.txt"}, manifest.CheckBirthArtifacts(map[string]bool{present: true}))
+	assert.Empty(t, manifest.CheckBirthArtifacts(map[string]bool{present: true, missing: true}))
+	assert.Equal(t, []string{"dangling.txt", "valid.txt"}, manifest.CheckBirthArtifacts(nil))
+}
+
+func TestGetManifestEntryFromArtifactFilePathNotFound(t *testing.T) {
+	manifest := Manifest{Contents: map[string]ManifestEntry{"a.txt": {Digest: "a"}}}
+	_, err := manifest.GetManifestEntryFromArtifactFilePath("b.txt")
+	assert.ErrorIs(t, err, ErrPathNotFound)
+	assert.ErrorContains(t, err, "path not contained in artifact: b.txt")
+}
+
+func TestManifestTagsRoundTrip(t *testing.T) {
+	manifest := Manifest{
+		Version:             1,
+		StoragePolicy:       "wandb-storage-policy-v1",
+		StoragePolicyConfig: StoragePolicyConfig{StorageLayout: StorageLayoutV2},
+		Contents: map[string]ManifestEntry{
+			"model.pt":  {Digest: "a", Size: 1, Extra: map[string]interface{}{}, Tags: []string{"checkpoint", "large"}},
+			"plain.txt": {Digest: "b", Size: 2, Extra: map[string]interface{}{}},
+		},
+	}
+
+	proto, err := manifest.ToProto()
+	assert.Nil(t, err)
+	roundTripped, err := NewManifestFromProto(proto)
+	assert.Nil(t, err)
+	assert.Equal(t, manifest, roundTripped)
+
+	data, err := json.Marshal(&manifest)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, strings.Count(string(data), `"tags":["checkpoint","large"]`))
+	var decoded Manifest
+	assert.Nil(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, manifest.Contents["model.pt"].Tags, decoded.Contents["model.pt"].Tags)
+	assert.Nil(t, decoded.Contents["plain.txt"].Tags)
+}
+
+func TestManifestValidateRejectsReferenceAndStoredEntries(t *testing.T) {
+	ref := "s3://bucket/key"
+	downloadURL := "https://storage.example.com/abc"
+	manifest := Manifest{Version: 1, Contents: map[string]ManifestEntry{
+		"reference.txt": {Ref: &ref, Digest: "etag"},
+		"stored.txt":    {Digest: "abc", DownloadURL: &downloadURL},
+	}}
+	assert.Nil(t, manifest.Validate())
+
+	manifest.Contents["b.txt"] = ManifestEntry{Ref: &ref, Digest: "abc", DownloadURL: &downloadURL}
+	manifest.Contents["a.txt"] = ManifestEntry{Ref: &ref, Digest: "abc", DownloadURL: &downloadURL}
+	assert.ErrorContains(t, manifest.Validate(),
+		"manifest entries are both references and stored files: a.txt, b.txt")
+}
+
+func TestManifestGroupByBirthArtifact(t *testing.T) {
+	first, second := "artifact-1", "artifact-2"
+	manifest := Manifest{Contents: map[string]ManifestEntry{
+		"b.txt": {Digest: "b", BirthArtifactID: &first},
+		"a.txt": {Digest: "a", BirthArtifactID: &first},
+		"c.txt": {Digest: "c", BirthArtifactID: &second},
+		"d.txt": {Digest: "d"},
+		"e.txt": {Digest: "e"},
+	}}
+
+	assert.Equal(t, map[string][]string{
+		first:  {"a.txt", "b.txt"},
+		second: {"c.txt"},
+		"":     {"d.txt", "e.txt"},
+	}, manifest.GroupByBirthArtifact())
+	assert.Empty(t, (&Manifest{}).GroupByBirthArtifact())
+}
+
+func TestManifestContentEncodingRoundTrip(t *testing.T) {
+	gzipEncoding := "gzip"
+	manifest := Manifest{
+		Version:             1,
+		StoragePolicy:       "wandb-storage-policy-v1",
+		StoragePolicyConfig: StoragePolicyConfig{StorageLayout: StorageLayoutV2},
+		Contents: map[string]ManifestEntry{
+			"data.csv.gz": {Digest: "a", Size: 1, Extra: map[string]interface{}{}, ContentEncoding: &gzipEncoding},
+			"plain.txt":   {Digest: "b", Size: 2, Extra: map[string]interface{}{}},
+		},
+	}
+
+	proto, err := manifest.ToProto()
+	assert.Nil(t, err)
+	roundTripped, err := NewManifestFromProto(proto)
+	assert.Nil(t, err)
+	assert.Equal(t, manifest, roundTripped)
+	compressed, plain := roundTripped.Contents["data.csv.gz"], roundTripped.Contents["plain.txt"]
+	assert.True(t, compressed.IsCompressed())
+	assert.False(t, plain.IsCompressed())
+
+	data, err := json.Marshal(&manifest)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, strings.Count(string(data), `"contentEncoding":"gzip"`))
+}
+
+func TestManifestEncryptionRoundTrip(t *testing.T) {
+	manifest := Manifest{
+		Version:             1,
+		StoragePolicy:       "wandb-storage-policy-v1",
+		StoragePolicyConfig: StoragePolicyConfig{StorageLayout: StorageLayoutV2},
+		Contents: map[string]ManifestEntry{
+			"secret.bin": {
+				Digest:     "a",
+				Size:       1,
+				Extra:      map[string]interface{}{},
+				Encryption: &EntryEncryption{Algorithm: "aws:kms", KeyID: "arn:aws:kms:us-east-1:123:key/abc"},
+			},
+			"plain.txt": {Digest: "b", Size: 2, Extra: map[string]interface{}{}},
+		},
+	}
+
+	proto, err := manifest.ToProto()
+	assert.Nil(t, err)
+	roundTripped, err := NewManifestFromProto(proto)
+	assert.Nil(t, err)
+	assert.Equal(t, manifest, roundTripped)
+	encrypted, plain := roundTripped.Contents["secret.bin"], roundTripped.Contents["plain.txt"]
+	assert.True(t, encrypted.IsEncrypted())
+	assert.False(t, plain.IsEncrypted())
+	assert.False(t, (&ManifestEntry{Digest: "c", Encryption: &EntryEncryption{}}).IsEncrypted())
+	assert.False(t, encrypted.EqualContent(ManifestEntry{Digest: "a", Size: 1}))
+
+	data, err := json.Marshal(&manifest)
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `"encryption":{"algorithm":"aws:kms","keyID":"arn:aws:kms:us-east-1:123:key/abc"}`)
+	assert.Equal(t, 1, strings.Count(string(data), `"encryption"`))
+}
+
+func FuzzManifestRoundTrip(f *testing.F) {
+	for _, seed := range []string{
+		`{"version":1,"storagePolicy":"wandb-storage-policy-v1","storagePolicyConfig":{"storageLayout":"V2"},"contents":{}}`,
+		`{"version":1,"contents":{"a.txt":{"digest":"abc","birthArtifactID":null,"size":1}}}`,
+		`{"version":1,"contents":{"ref.txt":{"digest":"etag","birthArtifactID":"birth","ref":"s3://bucket/key","size":3}}}`,
+		`{"version":1,"contents":{"a.txt":{"digest":"abc","size":1,"extra":{"etag":"x","n":1.5,"nested":{"list":[1,null,"y"]}}}}}`,
+		`{"version":1,"contents":{"link":{"digest":"d","size":0,"symlinkTarget":"a.txt","tags":["t"],"contentEncoding":""}}}`,
+		`{"version":1,"contents":{"secret.bin":{"digest":"e","size":1,"encryption":{"algorithm":"AES256"}}}}`,
+		`{"version":1,"contents":null,"storagePolicyConfig":{}}`,
+	} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var manifest Manifest
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return
+		}
+		encoded, err := json.Marshal(&manifest)
+		assert.Nil(t, err)
+		var roundTripped Manifest
+		assert.Nil(t, json.Unmarshal(encoded, &roundTripped))
+		assert.True(t, manifest.Equal(&roundTripped), "%s round-tripped to %s", data, encoded)
+
+		reencoded, err := json.Marshal(&roundTripped)
+		assert.Nil(t, err)
+		assert.Equal(t, string(encoded), string(reencoded))
+	})
+}
@@ -0,0 +1,79 @@
+package artifacts
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// ManifestTransport fetches manifests from locations that LoadManifest does
+// not otherwise support, such as an internal RPC service or object store.
+type ManifestTransport interface {
+	// CanHandle reports whether the transport can fetch from location.
+	CanHandle(location string) bool
+
+	// Fetch returns the raw JSON manifest stored at location.
+	Fetch(ctx context.Context, location string) ([]byte, error)
+}
+
+// registeredTransport is a registration made by RegisterManifestTransport.
+// Registrations are compared by pointer, so the same transport can be
+// registered twice and each registration removed on its own.
+type registeredTransport struct {
+	transport ManifestTransport
+}
+
+var (
+	manifestTransportsMu sync.RWMutex
+	manifestTransports   []*registeredTransport
+)
+
+// RegisterManifestTransport adds t to the transports consulted by
+// LoadManifest. Transports are consulted in the order they were registered,
+// before the built-in HTTP and file loaders.
+//
+// The returned function removes the registration; calling it more than once
+// has no further effect.
+func RegisterManifestTransport(t ManifestTransport) (unregister func()) {
+	registration := &registeredTransport{transport: t}
+	manifestTransportsMu.Lock()
+	defer manifestTransportsMu.Unlock()
+	manifestTransports = append(manifestTransports, registration)
+	return func() {
+		manifestTransportsMu.Lock()
+		defer manifestTransportsMu.Unlock()
+		for i, r := range manifestTransports {
+			if r == registration {
+				manifestTransports = append(manifestTransports[:i:i], manifestTransports[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// manifestTransport returns the first registered transport that can handle
+// location, if any.
+func manifestTransport(location string) (ManifestTransport, bool) {
+	manifestTransportsMu.RLock()
+	defer manifestTransportsMu.RUnlock()
+	for _, r := range manifestTransports {
+		if r.transport.CanHandle(location) {
+			return r.transport, true
+		}
+	}
+	return nil, false
+}
+
+// loadManifestWithTransport fetches and parses the manifest at location with
+// t.
+func loadManifestWithTransport(
+	ctx context.Context,
+	t ManifestTransport,
+	location string,
+) (Manifest, error) {
+	data, err := t.Fetch(ctx, location)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("error fetching manifest from %s: %w", location, err)
+	}
+	return parseManifest(data)
+}
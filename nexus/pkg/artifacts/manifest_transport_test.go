@@ -0,0 +1,67 @@
+package artifacts
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeS3ManifestTransport struct {
+	objects map[string]string
+	fetched []string
+}
+
+func (f *fakeS3ManifestTransport) CanHandle(location string) bool {
+	return strings.HasPrefix(location, "s3://")
+}
+
+func (f *fakeS3ManifestTransport) Fetch(ctx context.Context, location string) ([]byte, error) {
+	f.fetched = append(f.fetched, location)
+	object, ok := f.objects[location]
+	if !ok {
+		return nil, fmt.Errorf("no such key")
+	}
+	return []byte(object), nil
+}
+
+func TestLoadManifestWithTransport(t *testing.T) {
+	transport := &fakeS3ManifestTransport{objects: map[string]string{
+		"s3://bucket/manifest.json": `{"version":1,"contents":{"a.txt":{"digest":"x","size":1}}}`,
+	}}
+	t.Cleanup(RegisterManifestTransport(transport))
+
+	manifest, err := LoadManifest("s3://bucket/manifest.json")
+	assert.Nil(t, err)
+	assert.Equal(t, "x", manifest.Contents["a.txt"].Digest)
+
+	_, err = LoadManifest("s3://bucket/missing.json")
+	assert.ErrorContains(t, err, "no such key")
+	assert.Equal(t, []string{"s3://bucket/manifest.json", "s3://bucket/missing.json"}, transport.fetched)
+
+	// Locations the transport can't handle fall back to the built-in loaders.
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	assert.Nil(t, os.WriteFile(path, []byte(`{"version":1,"contents":{}}`), 0600))
+	_, err = LoadManifest(path)
+	assert.Nil(t, err)
+	assert.Len(t, transport.fetched, 2)
+}
+
+func TestUnregisterManifestTransport(t *testing.T) {
+	transport := &fakeS3ManifestTransport{objects: map[string]string{
+		"s3://bucket/manifest.json": `{"version":1,"contents":{}}`,
+	}}
+	unregister := RegisterManifestTransport(transport)
+	_, err := LoadManifest("s3://bucket/manifest.json")
+	assert.Nil(t, err)
+
+	unregister()
+	unregister()
+	_, err = LoadManifest("s3://bucket/manifest.json")
+	assert.NotNil(t, err)
+	assert.Len(t, transport.fetched, 1)
+}
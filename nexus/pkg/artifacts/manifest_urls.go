@@ -0,0 +1,254 @@
+package artifacts
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RefreshDownloadURLs replaces the DownloadURL of every entry that has one
+// with a fresh URL obtained from refresh.
+//
+// refresh is called with batches of up to BATCH_SIZE paths and returns the
+// new URL for each. Paths it omits keep their old URL; it is an error for it
+// to return a URL for a path it wasn't asked about.
+func (m *Manifest) RefreshDownloadURLs(
+	ctx context.Context,
+	refresh func(paths []string) (map[string]string, error),
+) error {
+	var paths []string
+	for _, path := range m.SortedPaths() {
+		if m.Contents[path].DownloadURL != nil {
+			paths = append(paths, path)
+		}
+	}
+
+	for start := 0; start < len(paths); start += BATCH_SIZE {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		end := min(start+BATCH_SIZE, len(paths))
+		batch := paths[start:end]
+		urls, err := refresh(batch)
+		if err != nil {
+			return fmt.Errorf("error refreshing download URLs: %w", err)
+		}
+
+		requested := make(map[string]bool, len(batch))
+		for _, path := range batch {
+			requested[path] = true
+		}
+		for path := range urls {
+			if !requested[path] {
+				return fmt.Errorf("refreshed download URL for unrequested path %q", path)
+			}
+		}
+		for path, url := range urls {
+			url := url
+			entry := m.Contents[path]
+			entry.DownloadURL = &url
+			m.Contents[path] = entry
+		}
+	}
+	return nil
+}
+
+// ResolveDownloadURL returns a download URL for the stored entry at path by
+// passing its storage key, as computed by StorageKey, to signer.
+//
+// This is for deployments that sign URLs on demand rather than recording
+// presigned URLs, which expire, in the manifest. The entry's DownloadURL is
+// neither used nor updated, and signer is only called once the key is known.
+func (m *Manifest) ResolveDownloadURL(
+	ctx context.Context,
+	path string,
+	signer func(ctx context.Context, storageKey string) (string, error),
+) (string, error) {
+	entry, err := m.GetManifestEntryFromArtifactFilePath(path)
+	if err != nil {
+		return "", err
+	}
+	storageKey, err := m.StorageKey(entry)
+	if err != nil {
+		return "", fmt.Errorf("error computing storage key for %q: %w", path, err)
+	}
+	url, err := signer(ctx, storageKey)
+	if err != nil {
+		return "", fmt.Errorf("error signing download URL for %q: %w", path, err)
+	}
+	return url, nil
+}
+
+// RewriteDownloadHosts replaces the host of each entry's DownloadURL with
+// mapping[host], if present, leaving the rest of the URL, including any
+// presigned query parameters, unchanged. Entries without a DownloadURL are
+// skipped.
+//
+// If any DownloadURL cannot be parsed, an error is returned and no entry is
+// changed.
+func (m *Manifest) RewriteDownloadHosts(mapping map[string]string) error {
+	rewritten := make(map[string]string)
+	for path, entry := range m.Contents {
+		if entry.DownloadURL == nil {
+			continue
+		}
+		downloadURL, err := url.Parse(*entry.DownloadURL)
+		if err != nil {
+			return fmt.Errorf("invalid download URL for manifest entry %q: %w", path, err)
+		}
+		host, ok := mapping[downloadURL.Host]
+		if !ok {
+			continue
+		}
+		downloadURL.Host = host
+		rewritten[path] = downloadURL.String()
+	}
+	for path, downloadURL := range rewritten {
+		downloadURL := downloadURL
+		entry := m.Contents[path]
+		entry.DownloadURL = &downloadURL
+		m.Contents[path] = entry
+	}
+	return nil
+}
+
+// DownloadURLExpiry returns the time at which the entry's presigned
+// DownloadURL expires, as encoded in its query parameters.
+//
+// AWS and GCS V4 signatures (X-Amz-Date with X-Amz-Expires, X-Goog-Date with
+// X-Goog-Expires), V2 signatures (Expires as a Unix time) and Azure SAS
+// tokens (se) are recognized. The second return value is false if the entry
+// has no DownloadURL or its expiry cannot be determined.
+func (e *ManifestEntry) DownloadURLExpiry() (time.Time, bool) {
+	if e.DownloadURL == nil {
+		return time.Time{}, false
+	}
+	downloadURL, err := url.Parse(*e.DownloadURL)
+	if err != nil {
+		return time.Time{}, false
+	}
+	query := downloadURL.Query()
+
+	for _, prefix := range []string{"X-Amz-", "X-Goog-"} {
+		date, expires := query.Get(prefix+"Date"), query.Get(prefix+"Expires")
+		if date == "" || expires == "" {
+			continue
+		}
+		signedAt, err := time.Parse("20060102T150405Z", date)
+		if err != nil {
+			return time.Time{}, false
+		}
+		seconds, err := strconv.ParseInt(expires, 10, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return signedAt.Add(time.Duration(seconds) * time.Second), true
+	}
+	if expires := query.Get("Expires"); expires != "" {
+		seconds, err := strconv.ParseInt(expires, 10, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return time.Unix(seconds, 0).UTC(), true
+	}
+	if se := query.Get("se"); se != "" {
+		expiry, err := time.Parse(time.RFC3339, se)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return expiry, true
+	}
+	return time.Time{}, false
+}
+
+// ExpiredBefore returns, in sorted order, the paths of entries whose
+// DownloadURL expires before t. Entries whose expiry is unknown are not
+// included.
+func (m *Manifest) ExpiredBefore(t time.Time) []string {
+	var paths []string
+	for path, entry := range m.Contents {
+		if expiry, ok := entry.DownloadURLExpiry(); ok && expiry.Before(t) {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// timeNow returns the current time. Tests replace it to control the clock.
+var timeNow = time.Now
+
+// ValidateURLsNotExpiringWithin returns, in sorted order, the paths of entries
+// whose DownloadURL has expired or expires less than d from now, so that they
+// can be refreshed before a long download starts. Entries whose expiry is
+// unknown are not included.
+func (m *Manifest) ValidateURLsNotExpiringWithin(d time.Duration) []string {
+	return m.ExpiredBefore(timeNow().Add(d))
+}
+
+// PreflightURLs sends a HEAD request to the DownloadURL of every entry that
+// has one, using at most workers concurrent requests, and returns the result
+// for each such path: nil if the URL responded with a 2xx status, or an error
+// describing the failure.
+//
+// If ctx is cancelled, paths that were not yet checked map to the context's
+// error. If client is nil, http.DefaultClient is used.
+func (m *Manifest) PreflightURLs(ctx context.Context, client *http.Client, workers int) map[string]error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	results := make(map[string]error)
+	mu := sync.Mutex{}
+	semaphore := make(chan struct{}, workers)
+	wg := sync.WaitGroup{}
+	for _, path := range m.SortedPaths() {
+		entry := m.Contents[path]
+		if entry.DownloadURL == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			mu.Lock()
+			results[path] = ctx.Err()
+			mu.Unlock()
+			continue
+		case semaphore <- struct{}{}:
+		}
+		wg.Add(1)
+		go func(path, url string) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			err := preflightURL(ctx, client, url)
+			mu.Lock()
+			defer mu.Unlock()
+			results[path] = err
+		}(path, *entry.DownloadURL)
+	}
+	wg.Wait()
+	return results
+}
+
+func preflightURL(ctx context.Context, client *http.Client, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("HEAD request failed with status code: %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+package artifacts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManifestRefreshDownloadURLs(t *testing.T) {
+	oldURL := "https://storage.example.com/old"
+	manifest := Manifest{Contents: map[string]ManifestEntry{
+		"a.txt":     {Digest: "a", DownloadURL: &oldURL},
+		"b.txt":     {Digest: "b", DownloadURL: &oldURL},
+		"c.txt":     {Digest: "c", DownloadURL: &oldURL},
+		"d.txt":     {Digest: "d", DownloadURL: &oldURL},
+		"local.txt": {Digest: "e"},
+	}}
+
+	var batches [][]string
+	err := manifest.RefreshDownloadURLs(context.Background(), func(paths []string) (map[string]string, error) {
+		batches = append(batches, append([]string(nil), paths...))
+		return map[string]string{
+			"a.txt": "https://storage.example.com/new-a",
+			"b.txt": "https://storage.example.com/new-b",
+			"c.txt": "https://storage.example.com/new-c",
+		}, nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{"a.txt", "b.txt", "c.txt", "d.txt"}}, batches)
+	for _, path := range []string{"a.txt", "b.txt", "c.txt"} {
+		expected := "https://storage.example.com/new-" + strings.TrimSuffix(path, ".txt")
+		assert.Equal(t, expected, *manifest.Contents[path].DownloadURL, path)
+	}
+	assert.Equal(t, oldURL, *manifest.Contents["d.txt"].DownloadURL)
+	assert.Nil(t, manifest.Contents["local.txt"].DownloadURL)
+
+	err = manifest.RefreshDownloadURLs(context.Background(), func(paths []string) (map[string]string, error) {
+		return map[string]string{"local.txt": "https://storage.example.com/x"}, nil
+	})
+	assert.ErrorContains(t, err, `unrequested path "local.txt"`)
+	assert.Nil(t, manifest.Contents["local.txt"].DownloadURL)
+}
+
+func TestManifestPreflightURLs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodHead, r.Method)
+		if strings.HasPrefix(r.URL.Path, "/expired") {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+
+	okURL, expiredURL := server.URL+"/ok", server.URL+"/expired"
+	manifest := Manifest{Contents: map[string]ManifestEntry{
+		"ok.txt":      {Digest: "a", DownloadURL: &okURL},
+		"expired.txt": {Digest: "b", DownloadURL: &expiredURL},
+		"local.txt":   {Digest: "c"},
+	}}
+
+	results := manifest.PreflightURLs(context.Background(), server.Client(), 2)
+	assert.Len(t, results, 2)
+	assert.Nil(t, results["ok.txt"])
+	assert.ErrorContains(t, results["expired.txt"], "403")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results = manifest.PreflightURLs(ctx, server.Client(), 2)
+	assert.ErrorIs(t, results["ok.txt"], context.Canceled)
+	assert.ErrorIs(t, results["expired.txt"], context.Canceled)
+}
+
+func TestManifestPreflightURLsCancelledMidRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var once sync.Once
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Cancel while the first requests are still in flight, so that their
+		// results are recorded while the remaining paths are being skipped.
+		once.Do(cancel)
+		time.Sleep(10 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	manifest := Manifest{Contents: map[string]ManifestEntry{}}
+	for i := 0; i < 50; i++ {
+		url := fmt.Sprintf("%s/%d", server.URL, i)
+		manifest.Contents[fmt.Sprintf("file-%02d.txt", i)] = ManifestEntry{Digest: "a", DownloadURL: &url}
+	}
+
+	results := manifest.PreflightURLs(ctx, server.Client(), 2)
+	assert.Len(t, results, 50)
+	assert.ErrorIs(t, results["file-49.txt"], context.Canceled)
+}
+
+func TestManifestRewriteDownloadHosts(t *testing.T) {
+	signed := "https://bucket.s3.amazonaws.com/dir/a%20b.txt?X-Amz-Signature=abc%2Fdef&X-Amz-Expires=3600"
+	other := "https://storage.googleapis.com/bucket/c.txt?Signature=xyz"
+	manifest := Manifest{Contents: map[string]ManifestEntry{
+		"a.txt": {Digest: "a", DownloadURL: &signed},
+		"c.txt": {Digest: "c", DownloadURL: &other},
+		"d.txt": {Digest: "d"},
+	}}
+
+	err := manifest.RewriteDownloadHosts(map[string]string{
+		"bucket.s3.amazonaws.com": "mirror.internal:9000",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t,
+		"https://mirror.internal:9000/dir/a%20b.txt?X-Amz-Signature=abc%2Fdef&X-Amz-Expires=3600",
+		*manifest.Contents["a.txt"].DownloadURL,
+	)
+	assert.Equal(t, other, *manifest.Contents["c.txt"].DownloadURL)
+	assert.Nil(t, manifest.Contents["d.txt"].DownloadURL)
+
+	invalid := "https://bad host/"
+	manifest.Contents["e.txt"] = ManifestEntry{Digest: "e", DownloadURL: &invalid}
+	err = manifest.RewriteDownloadHosts(map[string]string{"storage.googleapis.com": "mirror"})
+	assert.ErrorContains(t, err, "invalid download URL")
+	assert.Equal(t, other, *manifest.Contents["c.txt"].DownloadURL)
+}
+
+func TestManifestEntryDownloadURLExpiry(t *testing.T) {
+	signedAt := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	for rawURL, expected := range map[string]time.Time{
+		"https://bucket.s3.amazonaws.com/a?X-Amz-Date=20230901T120000Z&X-Amz-Expires=3600&X-Amz-Signature=x": signedAt.Add(time.Hour),
+		"https://storage.googleapis.com/b/a?X-Goog-Date=20230901T120000Z&X-Goog-Expires=600":                 signedAt.Add(10 * time.Minute),
+		"https://storage.googleapis.com/b/a?Expires=1693569600&Signature=x":                                  signedAt,
+		"https://account.blob.core.windows.net/c/a?se=2023-09-01T12%3A00%3A00Z&sig=x":                        signedAt,
+	} {
+		entry := ManifestEntry{Digest: "a", DownloadURL: &rawURL}
+		expiry, ok := entry.DownloadURLExpiry()
+		assert.True(t, ok, rawURL)
+		assert.True(t, expected.Equal(expiry), "%s: got %v", rawURL, expiry)
+	}
+
+	for _, rawURL := range []string{
+		"https://example.com/a",
+		"https://bucket.s3.amazonaws.com/a?X-Amz-Date=yesterday&X-Amz-Expires=3600",
+	} {
+		entry := ManifestEntry{Digest: "a", DownloadURL: &rawURL}
+		_, ok := entry.DownloadURLExpiry()
+		assert.False(t, ok, rawURL)
+	}
+	_, ok := (&ManifestEntry{Digest: "a"}).DownloadURLExpiry()
+	assert.False(t, ok)
+}
+
+func TestManifestExpiredBefore(t *testing.T) {
+	soon := "https://bucket.s3.amazonaws.com/a?X-Amz-Date=20230901T120000Z&X-Amz-Expires=60"
+	later := "https://bucket.s3.amazonaws.com/b?X-Amz-Date=20230901T120000Z&X-Amz-Expires=86400"
+	unknown := "https://example.com/c"
+	manifest := Manifest{Contents: map[string]ManifestEntry{
+		"a.txt": {Digest: "a", DownloadURL: &soon},
+		"b.txt": {Digest: "b", DownloadURL: &later},
+		"c.txt": {Digest: "c", DownloadURL: &unknown},
+		"d.txt": {Digest: "d"},
+	}}
+
+	cutoff := time.Date(2023, 9, 1, 13, 0, 0, 0, time.UTC)
+	assert.Equal(t, []string{"a.txt"}, manifest.ExpiredBefore(cutoff))
+	assert.Equal(t, []string{"a.txt", "b.txt"}, manifest.ExpiredBefore(cutoff.Add(48*time.Hour)))
+	assert.Empty(t, manifest.ExpiredBefore(cutoff.Add(-24*time.Hour)))
+}
+
+func TestManifestResolveDownloadURL(t *testing.T) {
+	// base64 MD5 of "contents".
+	const digest = "mL99jBV4Two9YyBEQeHiqg=="
+	const md5Hex = "98bf7d8c15784f0a3d63204441e1e2aa"
+	birthID := "QXJ0aWZhY3Q6MTIz"
+	staleURL := "https://storage.example.com/stale"
+	contents := map[string]ManifestEntry{
+		"a.txt": {Digest: digest, BirthArtifactID: &birthID, DownloadURL: &staleURL},
+	}
+
+	var keys []string
+	signer := func(ctx context.Context, storageKey string) (string, error) {
+		keys = append(keys, storageKey)
+		return "https://storage.example.com/signed/" + storageKey, nil
+	}
+
+	v1 := Manifest{StoragePolicyConfig: StoragePolicyConfig{StorageLayout: StorageLayoutV1}, Contents: contents}
+	url, err := v1.ResolveDownloadURL(context.Background(), "a.txt", signer)
+	assert.Nil(t, err)
+	assert.Equal(t, "https://storage.example.com/signed/"+md5Hex, url)
+
+	v2 := Manifest{StoragePolicyConfig: StoragePolicyConfig{StorageLayout: StorageLayoutV2}, Contents: contents}
+	url, err = v2.ResolveDownloadURL(context.Background(), "a.txt", signer)
+	assert.Nil(t, err)
+	assert.Equal(t, "https://storage.example.com/signed/"+birthID+"/"+md5Hex, url)
+	assert.Equal(t, []string{md5Hex, birthID + "/" + md5Hex}, keys)
+	assert.Equal(t, staleURL, *v2.Contents["a.txt"].DownloadURL)
+
+	_, err = v2.ResolveDownloadURL(context.Background(), "missing.txt", signer)
+	assert.ErrorIs(t, err, ErrPathNotFound)
+	_, err = v2.ResolveDownloadURL(context.Background(), "a.txt",
+		func(ctx context.Context, storageKey string) (string, error) {
+			return "", errors.New("signing service unavailable")
+		})
+	assert.ErrorContains(t, err, "signing service unavailable")
+	assert.Len(t, keys, 2)
+}
+
+func TestManifestValidateURLsNotExpiringWithin(t *testing.T) {
+	// Signed at 12:00, expiring at 12:10, 13:00 and the next day at 12:00.
+	tenMinutes := "https://bucket.s3.amazonaws.com/a?X-Amz-Date=20230901T120000Z&X-Amz-Expires=600"
+	hour := "https://storage.googleapis.com/b?X-Goog-Date=20230901T120000Z&X-Goog-Expires=3600"
+	day := "https://account.blob.core.windows.net/c?se=2023-09-02T12%3A00%3A00Z&sig=x"
+	unknown := "https://example.com/d"
+	manifest := Manifest{Contents: map[string]ManifestEntry{
+		"a.txt": {Digest: "a", DownloadURL: &tenMinutes},
+		"b.txt": {Digest: "b", DownloadURL: &hour},
+		"c.txt": {Digest: "c", DownloadURL: &day},
+		"d.txt": {Digest: "d", DownloadURL: &unknown},
+		"e.txt": {Digest: "e"},
+	}}
+
+	defer func(original func() time.Time) { timeNow = original }(timeNow)
+	now := time.Date(2023, 9, 1, 12, 5, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+
+	assert.Empty(t, manifest.ValidateURLsNotExpiringWithin(time.Minute))
+	assert.Equal(t, []string{"a.txt"}, manifest.ValidateURLsNotExpiringWithin(30*time.Minute))
+	assert.Equal(t, []string{"a.txt", "b.txt"}, manifest.ValidateURLsNotExpiringWithin(2*time.Hour))
+	assert.Equal(t, []string{"a.txt", "b.txt", "c.txt"}, manifest.ValidateURLsNotExpiringWithin(48*time.Hour))
+
+	// Half an hour later, the first URL has already expired.
+	now = now.Add(30 * time.Minute)
+	assert.Equal(t, []string{"a.txt"}, manifest.ValidateURLsNotExpiringWithin(0))
+}
@@ -0,0 +1,175 @@
+package artifacts
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+)
+
+// DigestAlgorithm is a hash function used to compute manifest digests.
+type DigestAlgorithm string
+
+const (
+	// DigestAlgorithmMD5 is the algorithm the W&B backend expects, and the
+	// default.
+	DigestAlgorithmMD5 DigestAlgorithm = "md5"
+
+	// DigestAlgorithmSHA256 is an alternative for environments, such as
+	// FIPS-mode deployments, where MD5 is not allowed.
+	DigestAlgorithmSHA256 DigestAlgorithm = "sha256"
+)
+
+// newHash returns a new hash.Hash for the algorithm. The empty algorithm
+// means MD5.
+func (a DigestAlgorithm) newHash() (hash.Hash, error) {
+	switch a {
+	case "", DigestAlgorithmMD5:
+		return md5.New(), nil
+	case DigestAlgorithmSHA256:
+		return sha256.New(), nil
+	default:
+		return nil, fmt.Errorf("unsupported digest algorithm %q", a)
+	}
+}
+
+// ManifestWriter serializes manifests to files.
+type ManifestWriter struct {
+	// Dir is the directory to create files in. If empty, the default
+	// temporary directory is used.
+	Dir string
+
+	// DigestAlgorithm is used to compute the digest of the written manifest.
+	// If empty, DigestAlgorithmMD5 is used.
+	DigestAlgorithm DigestAlgorithm
+}
+
+// WrittenManifest describes a manifest file created by a ManifestWriter.
+type WrittenManifest struct {
+	// Filename is the path to the written file.
+	Filename string
+
+	// Digest is the base64-encoded digest of the file's contents.
+	Digest string
+
+	// DigestAlgorithm is the algorithm used to compute Digest.
+	DigestAlgorithm DigestAlgorithm
+}
+
+// tempFile is the subset of *os.File used by ManifestWriter.
+type tempFile interface {
+	io.WriteCloser
+	Name() string
+}
+
+// createTempFile creates the file written by ManifestWriter. Tests replace it
+// to inject write failures.
+var createTempFile = func(dir, pattern string) (tempFile, error) {
+	return os.CreateTemp(dir, pattern)
+}
+
+// Write streams m as JSON to dst, returning the number of bytes written and
+// the base64-encoded digest of those bytes.
+//
+// The output is identical to json.Marshal(m).
+func (w *ManifestWriter) Write(dst io.Writer, m *Manifest) (n int64, digest string, err error) {
+	hasher, err := w.digestAlgorithm().newHash()
+	if err != nil {
+		return 0, "", err
+	}
+	counter := &countingWriter{w: io.MultiWriter(dst, hasher)}
+	encoder := json.NewEncoder(&newlineTrimmer{w: counter})
+	if err := encoder.Encode(m); err != nil {
+		return counter.n, "", err
+	}
+	return counter.n, base64.StdEncoding.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// WriteToFile writes m as JSON to a new temporary file.
+//
+// On error, the temporary file is removed.
+func (w *ManifestWriter) WriteToFile(m *Manifest) (written WrittenManifest, rerr error) {
+	if _, rerr = w.digestAlgorithm().newHash(); rerr != nil {
+		return
+	}
+
+	f, rerr := createTempFile(w.Dir, "tmpfile-")
+	if rerr != nil {
+		return
+	}
+	defer func() {
+		if err := f.Close(); err != nil && rerr == nil {
+			rerr = err
+		}
+		if rerr != nil {
+			_ = os.Remove(f.Name())
+			written = WrittenManifest{}
+		}
+	}()
+
+	_, digest, rerr := w.Write(f, m)
+	if rerr != nil {
+		return
+	}
+
+	written = WrittenManifest{
+		Filename:        f.Name(),
+		Digest:          digest,
+		DigestAlgorithm: w.digestAlgorithm(),
+	}
+	return
+}
+
+func (w *ManifestWriter) digestAlgorithm() DigestAlgorithm {
+	if w.DigestAlgorithm == "" {
+		return DigestAlgorithmMD5
+	}
+	return w.DigestAlgorithm
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// newlineTrimmer forwards writes to w, except for a final trailing newline.
+//
+// json.Encoder terminates each value with a newline that json.Marshal does
+// not emit; dropping it keeps the output and its digest identical to what
+// json.Marshal produces.
+type newlineTrimmer struct {
+	w       io.Writer
+	pending bool
+}
+
+func (t *newlineTrimmer) Write(p []byte) (int, error) {
+	n := len(p)
+	if n == 0 {
+		return 0, nil
+	}
+	if t.pending {
+		if _, err := t.w.Write([]byte{'\n'}); err != nil {
+			return 0, err
+		}
+		t.pending = false
+	}
+	if p[n-1] == '\n' {
+		t.pending = true
+		p = p[:n-1]
+	}
+	if _, err := t.w.Write(p); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
@@ -0,0 +1,75 @@
+package artifacts
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManifestWriterDigestAlgorithms(t *testing.T) {
+	manifest := Manifest{
+		Version:  1,
+		Contents: map[string]ManifestEntry{"a.txt": {Digest: "abc", Size: 3}},
+	}
+
+	md5Written, err := (&ManifestWriter{Dir: t.TempDir()}).WriteToFile(&manifest)
+	assert.Nil(t, err)
+	assert.Equal(t, DigestAlgorithmMD5, md5Written.DigestAlgorithm)
+
+	sha256Written, err := (&ManifestWriter{
+		Dir:             t.TempDir(),
+		DigestAlgorithm: DigestAlgorithmSHA256,
+	}).WriteToFile(&manifest)
+	assert.Nil(t, err)
+	assert.Equal(t, DigestAlgorithmSHA256, sha256Written.DigestAlgorithm)
+	assert.NotEqual(t, md5Written.Digest, sha256Written.Digest)
+
+	data, err := os.ReadFile(sha256Written.Filename)
+	assert.Nil(t, err)
+	md5Sum := md5.Sum(data)
+	sha256Sum := sha256.Sum256(data)
+	assert.Equal(t, base64.StdEncoding.EncodeToString(md5Sum[:]), md5Written.Digest)
+	assert.Equal(t, base64.StdEncoding.EncodeToString(sha256Sum[:]), sha256Written.Digest)
+
+	_, digest, err := manifest.WriteToFileInDir(t.TempDir())
+	assert.Nil(t, err)
+	assert.Equal(t, md5Written.Digest, digest)
+}
+
+func TestManifestWriterUnsupportedAlgorithm(t *testing.T) {
+	manifest := Manifest{Version: 1}
+	_, err := (&ManifestWriter{DigestAlgorithm: "crc32"}).WriteToFile(&manifest)
+	assert.ErrorContains(t, err, "unsupported digest algorithm")
+}
+
+func TestManifestWriteToMatchesWriteToFile(t *testing.T) {
+	manifest := Manifest{
+		Version:  1,
+		Contents: map[string]ManifestEntry{"a.txt": {Digest: "abc", Size: 3}},
+	}
+
+	var buf bytes.Buffer
+	n, digest, err := manifest.WriteToWithDigest(&buf)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(buf.Len()), n)
+
+	filename, fileDigest, err := manifest.WriteToFileInDir(t.TempDir())
+	assert.Nil(t, err)
+	assert.Equal(t, fileDigest, digest)
+	data, err := os.ReadFile(filename)
+	assert.Nil(t, err)
+	assert.Equal(t, data, buf.Bytes())
+
+	var writerTo io.WriterTo = &manifest
+	var buf2 bytes.Buffer
+	n, err = writerTo.WriteTo(&buf2)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(buf2.Len()), n)
+	assert.Equal(t, buf.Bytes(), buf2.Bytes())
+}
@@ -4,6 +4,8 @@ import (
 	"crypto/md5"
 	"encoding/base64"
 	"encoding/hex"
+	"io"
+	"os"
 )
 
 func ComputeB64MD5(data []byte) (string, error) {
@@ -15,6 +17,19 @@ func ComputeB64MD5(data []byte) (string, error) {
 	return base64.StdEncoding.EncodeToString(hasher.Sum(nil)), nil
 }
 
+func ComputeFileB64MD5(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hasher := md5.New()
+	if _, err := io.Copy(hasher, f); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(hasher.Sum(nil)), nil
+}
+
 func B64ToHex(data string) (string, error) {
 	buf, err := base64.StdEncoding.DecodeString(data)
 	if err != nil {
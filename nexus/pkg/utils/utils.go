@@ -7,3 +7,11 @@ func NilIfZero[T comparable](x T) *T {
 	}
 	return &x
 }
+
+func ZeroIfNil[T any](x *T) T {
+	if x == nil {
+		var zero T
+		return zero
+	}
+	return *x
+}